@@ -0,0 +1,216 @@
+package influx
+
+import (
+	"container/list"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Supported values of Config.CardinalityAction, what the writer does with a
+// metric which exceeds cardinality limits
+const (
+	// CardinalityDrop discards the metric
+	CardinalityDrop = "drop"
+	// CardinalityOther replaces the offending tag values with "other"
+	CardinalityOther = "other"
+	// CardinalityField moves the offending tags to fields
+	CardinalityField = "field"
+)
+
+// cardinalityOther is the tag value used by CardinalityOther
+const cardinalityOther = "other"
+
+// CardinalityError is reported when a metric is dropped because of cardinality limits.
+type CardinalityError struct {
+	Measurement string
+	// Tag is the key whose value exceeded MaxTagValues, empty when the
+	// measurement exceeded MaxSeriesPerMeasurement
+	Tag   string
+	Value string
+}
+
+func (e *CardinalityError) Error() string {
+	if e.Tag == "" {
+		return fmt.Sprintf("influx: too many series of measurement %s", e.Measurement)
+	}
+	return fmt.Sprintf("influx: too many values of tag %s, measurement %s value %s", e.Tag, e.Measurement, e.Value)
+}
+
+// lru is a bounded set of recently seen keys, keys which were not seen for
+// the window make room for new ones
+type lru struct {
+	capacity int
+	window   time.Duration
+	order    *list.List
+	items    map[string]*list.Element
+}
+
+type lruItem struct {
+	key  string
+	seen time.Time
+}
+
+func newLRU(capacity int, window time.Duration) *lru {
+	return &lru{
+		capacity: capacity,
+		window:   window,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// touch marks the key as seen now, it returns false if the key is new and
+// there is no room for it
+func (l *lru) touch(key string, now time.Time) bool {
+	if !l.admits(key, now) {
+		return false
+	}
+	if e, ok := l.items[key]; ok {
+		e.Value.(*lruItem).seen = now
+		l.order.MoveToFront(e)
+		return true
+	}
+	if l.order.Len() >= l.capacity {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.items, oldest.Value.(*lruItem).key)
+	}
+	l.items[key] = l.order.PushFront(&lruItem{key: key, seen: now})
+	return true
+}
+
+// admits reports whether touch would accept the key without changing the set
+func (l *lru) admits(key string, now time.Time) bool {
+	if _, ok := l.items[key]; ok || l.order.Len() < l.capacity {
+		return true
+	}
+	return now.Sub(l.order.Back().Value.(*lruItem).seen) >= l.window
+}
+
+// cardinalityGuard limits distinct values of every tag key and series of
+// every measurement. Only the last seen values and series up to the limits
+// are kept, but a set of them is kept for every tag key and measurement
+// ever seen, so memory is bounded by the limits times the number of tag keys
+// and measurements. Tag keys and measurement names themselves are expected
+// to come from the code rather than from data and are not limited.
+type cardinalityGuard struct {
+	maxTagValues int
+	maxSeries    int
+	action       string
+	window       time.Duration
+	mutex        sync.Mutex
+	tagValues    map[string]*lru
+	series       map[string]*lru
+	now          func() time.Time
+}
+
+func newCardinalityGuard(cfg Config) *cardinalityGuard {
+	return &cardinalityGuard{
+		maxTagValues: cfg.MaxTagValues,
+		maxSeries:    cfg.MaxSeriesPerMeasurement,
+		action:       cfg.CardinalityAction,
+		window:       mustParseDuration(cfg.CardinalityWindow),
+		tagValues:    make(map[string]*lru),
+		series:       make(map[string]*lru),
+		now:          time.Now,
+	}
+}
+
+// check applies the limits to the tags of a metric. It returns the tags and
+// values to write, copies are made when they are changed, violations is the
+// number of exceeded limits and err is set when the metric must be dropped.
+// Only the tag values and the series which are written count towards the
+// limits.
+func (g *cardinalityGuard) check(measurement string, tags map[string]string, values map[string]interface{}) (map[string]string, map[string]interface{}, int, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	now := g.now()
+	violations := 0
+
+	if g.maxTagValues > 0 {
+		keys := make([]string, 0, len(tags))
+		for k := range tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		var offending []string
+		for _, k := range keys {
+			if seen, ok := g.tagValues[k]; ok && !seen.admits(tags[k], now) {
+				offending = append(offending, k)
+			}
+		}
+		violations += len(offending)
+		if len(offending) > 0 {
+			if g.action == CardinalityDrop {
+				k := offending[0]
+				return nil, nil, violations, &CardinalityError{Measurement: measurement, Tag: k, Value: tags[k]}
+			}
+			tags, values = g.rewrite(tags, values, offending)
+		}
+	}
+
+	var series *lru
+	if g.maxSeries > 0 {
+		var ok bool
+		if series, ok = g.series[measurement]; !ok {
+			series = newLRU(g.maxSeries, g.window)
+			g.series[measurement] = series
+		}
+		if !series.admits(seriesKey(measurement, tags), now) {
+			violations++
+			if g.action == CardinalityDrop {
+				return nil, nil, violations, &CardinalityError{Measurement: measurement}
+			}
+			keys := make([]string, 0, len(tags))
+			for k := range tags {
+				keys = append(keys, k)
+			}
+			tags, values = g.rewrite(tags, values, keys)
+		}
+	}
+
+	// the metric is accepted, the written values and series are recorded,
+	// the overflow series made by rewrite may not fit and is written anyway
+	if g.maxTagValues > 0 {
+		for k, v := range tags {
+			seen, ok := g.tagValues[k]
+			if !ok {
+				seen = newLRU(g.maxTagValues, g.window)
+				g.tagValues[k] = seen
+			}
+			seen.touch(v, now)
+		}
+	}
+	if series != nil {
+		series.touch(seriesKey(measurement, tags), now)
+	}
+	return tags, values, violations, nil
+}
+
+// rewrite replaces the values of the tags with "other" or moves them to fields
+func (g *cardinalityGuard) rewrite(tags map[string]string, values map[string]interface{}, keys []string) (map[string]string, map[string]interface{}) {
+	newTags := make(map[string]string, len(tags))
+	for k, v := range tags {
+		newTags[k] = v
+	}
+	if g.action == CardinalityOther {
+		for _, k := range keys {
+			newTags[k] = cardinalityOther
+		}
+		return newTags, values
+	}
+
+	newValues := make(map[string]interface{}, len(values)+len(keys))
+	for k, v := range values {
+		newValues[k] = v
+	}
+	for _, k := range keys {
+		if _, ok := newValues[k]; !ok {
+			newValues[k] = newTags[k]
+		}
+		delete(newTags, k)
+	}
+	return newTags, newValues
+}
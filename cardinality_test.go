@@ -0,0 +1,103 @@
+package influx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCardinalityGuard(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	guard := func(action string) *cardinalityGuard {
+		g := newCardinalityGuard(Config{MaxTagValues: 2, MaxSeriesPerMeasurement: 3, CardinalityAction: action, CardinalityWindow: "1h"})
+		g.now = func() time.Time { return now }
+		return g
+	}
+	values := map[string]interface{}{"value": 1}
+
+	g := guard(CardinalityDrop)
+	for _, worker := range []string{"a", "b", "a"} {
+		_, _, violations, err := g.check("shares", map[string]string{"worker": worker}, values)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, violations)
+	}
+	_, _, violations, err := g.check("shares", map[string]string{"worker": "c"}, values)
+	assert.Equal(t, 1, violations)
+	assert.Equal(t, &CardinalityError{Measurement: "shares", Tag: "worker", Value: "c"}, err)
+	// the values make room for new ones after the window
+	now = now.Add(2 * time.Hour)
+	_, _, _, err = g.check("shares", map[string]string{"worker": "c"}, values)
+	assert.NoError(t, err)
+
+	// series limit with two tags within the value limit
+	g = guard(CardinalityDrop)
+	for _, tags := range []map[string]string{{"a": "1", "b": "1"}, {"a": "1", "b": "2"}, {"a": "2", "b": "1"}} {
+		_, _, _, err := g.check("shares", tags, values)
+		assert.NoError(t, err)
+	}
+	_, _, _, err = g.check("shares", map[string]string{"a": "2", "b": "2"}, values)
+	assert.Equal(t, &CardinalityError{Measurement: "shares"}, err)
+	_, _, _, err = g.check("blocks", map[string]string{"a": "2", "b": "2"}, values)
+	assert.NoError(t, err)
+
+	// values of a dropped metric don't take room
+	g = guard(CardinalityDrop)
+	g.check("shares", map[string]string{"worker": "a", "coin": "btc"}, values)
+	g.check("shares", map[string]string{"worker": "b", "coin": "btc"}, values)
+	_, _, _, err = g.check("shares", map[string]string{"worker": "c", "coin": "ltc"}, values)
+	assert.Error(t, err)
+	_, _, _, err = g.check("shares", map[string]string{"worker": "a", "coin": "xmr"}, values)
+	assert.NoError(t, err)
+
+	// rewritten series count towards the series limit
+	g = newCardinalityGuard(Config{MaxTagValues: 2, MaxSeriesPerMeasurement: 2, CardinalityAction: CardinalityOther, CardinalityWindow: "1h"})
+	g.check("shares", map[string]string{"worker": "a"}, values)
+	g.check("shares", map[string]string{"worker": "b"}, values)
+	tags, _, violations, err := g.check("shares", map[string]string{"worker": "c"}, values)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, violations)
+	assert.Equal(t, map[string]string{"worker": "other"}, tags)
+
+	g = guard(CardinalityOther)
+	g.check("shares", map[string]string{"worker": "a"}, values)
+	g.check("shares", map[string]string{"worker": "b"}, values)
+	original := map[string]string{"worker": "c", "coin": "btc"}
+	tags, newValues, violations, err := g.check("shares", original, values)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, violations)
+	assert.Equal(t, map[string]string{"worker": "other", "coin": "btc"}, tags)
+	assert.Equal(t, "c", original["worker"])
+	assert.Equal(t, values, newValues)
+
+	g = guard(CardinalityField)
+	g.check("shares", map[string]string{"worker": "a"}, values)
+	g.check("shares", map[string]string{"worker": "b"}, values)
+	tags, newValues, _, err = g.check("shares", map[string]string{"worker": "c", "coin": "btc"}, values)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"coin": "btc"}, tags)
+	assert.Equal(t, map[string]interface{}{"value": 1, "worker": "c"}, newValues)
+	assert.Len(t, values, 1)
+}
+
+func TestCardinalityStats(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	w, err := NewWriter(Config{Endpoint: srv.URL, BatchInterval: "1h", MaxTagValues: 1, ErrorHandler: NopErrorHandler{}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	for _, worker := range []string{"a", "a", "b", "c"} {
+		w.Write(SimpleMetric{Name: "shares", TagsMap: map[string]string{"worker": worker}, ValuesMap: map[string]interface{}{"value": 1}})
+	}
+	assert.NoError(t, w.Close())
+	stats := w.Stats()
+	assert.EqualValues(t, 2, stats.CardinalityViolations)
+	assert.EqualValues(t, 2, stats.DroppedCardinality)
+	assert.EqualValues(t, 2, stats.PointsWritten)
+}
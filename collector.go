@@ -0,0 +1,78 @@
+package influx
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultCollectInterval is used by reporters and collectors created with
+// a non-positive interval
+const DefaultCollectInterval = 10 * time.Second
+
+// collectInterval returns the interval or the default one if it isn't positive
+func collectInterval(d time.Duration) time.Duration {
+	if d <= 0 {
+		return DefaultCollectInterval
+	}
+	return d
+}
+
+// collector runs collect every interval between Start and Stop
+type collector struct {
+	interval time.Duration
+	collect  func()
+	mutex    sync.Mutex // protects stop
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// Start starts collecting every interval, it does nothing if the collector
+// is already started.
+func (c *collector) Start() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.stop != nil {
+		return
+	}
+	c.stop = make(chan struct{})
+	c.wg.Add(1)
+	go c.run(c.stop)
+}
+
+// Stop stops collecting and waits for the collector goroutine to exit, the
+// collector can be started again.
+func (c *collector) Stop() {
+	c.mutex.Lock()
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+	}
+	c.mutex.Unlock()
+	c.wg.Wait()
+}
+
+func (c *collector) run(stop chan struct{}) {
+	defer c.wg.Done()
+	ticker := time.NewTicker(collectInterval(c.interval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.collect()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// copyTags returns a copy of the tags, so points don't share the map of the options
+func copyTags(tags map[string]string) map[string]string {
+	if len(tags) == 0 {
+		return nil
+	}
+	ret := make(map[string]string, len(tags))
+	for k, v := range tags {
+		ret[k] = v
+	}
+	return ret
+}
@@ -0,0 +1,342 @@
+package influx
+
+import (
+	"compress/gzip"
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/influxdata/influxdb/client/v2"
+)
+
+// Supported values of Config.Protocol
+const (
+	ProtocolHTTP = "http"
+	ProtocolUDP  = "udp"
+)
+
+// Supported values of Config.OverflowPolicy, what Write does when the queue is full
+const (
+	// OverflowDropNewest discards the metric being written
+	OverflowDropNewest = "drop-newest"
+	// OverflowDropOldest discards the oldest queued metric to make room
+	OverflowDropOldest = "drop-oldest"
+	// OverflowBlock waits until there is room in the queue
+	OverflowBlock = "block"
+	// OverflowBlockTimeout waits for room up to Config.BlockTimeout
+	OverflowBlockTimeout = "block-timeout"
+)
+
+// Defaults used for the omitted Config fields
+const (
+	DefaultBatchInterval = "1s"
+	DefaultBatchCount    = 1000
+	DefaultWorkerCount   = 1
+	DefaultPrecision     = "ns"
+	DefaultBlockTimeout  = "1s"
+	// DefaultCardinalityWindow is how long a tag value or a series counts
+	// towards the cardinality limits after it was seen last
+	DefaultCardinalityWindow = "1h"
+	DefaultFailoverCooldown  = "30s"
+	// DefaultWriteTimeout limits http requests, so a stalled server doesn't
+	// block a worker forever
+	DefaultWriteTimeout = "10s"
+)
+
+// Config represents config values stored in json. Omitted fields get
+// Default* values, durations are strings accepted by time.ParseDuration.
+type Config struct {
+	Endpoint      string `json:"endpoint"`
+	Database      string `json:"database"`
+	User          string `json:"user"`
+	Password      string `json:"password"`
+	Host          string `json:"host"`
+	Label         string `json:"label"`
+	BatchInterval string `json:"batch_interval"`
+	BatchCount    int    `json:"batch_count"`
+	WorkerCount   int    `json:"worker_count"`
+	Precision     string `json:"precision"`
+	// Protocol is either "http" (default) or "udp". For udp Endpoint is "host:port".
+	// UDPPayloadSize is the maximum size of a datagram, 512 by default.
+	Protocol       string `json:"protocol"`
+	UDPPayloadSize int    `json:"udp_payload_size"`
+	// WriteTimeout limits http write requests, DefaultWriteTimeout by default.
+	WriteTimeout string `json:"write_timeout"`
+	// RetryMaxAttempts is the number of tries of a batch write, retries are
+	// made only for network and server errors. Backoff doubles from
+	// RetryBaseBackoff up to RetryMaxBackoff, RetryJitter (0..1) randomly
+	// shortens every delay by up to that fraction.
+	RetryMaxAttempts int     `json:"retry_max_attempts"`
+	RetryBaseBackoff string  `json:"retry_base_backoff"`
+	RetryMaxBackoff  string  `json:"retry_max_backoff"`
+	RetryJitter      float64 `json:"retry_jitter"`
+	// SpoolDir enables keeping batches which failed to be written on disk,
+	// they are replayed in order once influx is reachable again. The oldest
+	// segments are discarded when the spool exceeds SpoolMaxBytes or they
+	// are older than SpoolMaxAge, zero values mean no limit.
+	SpoolDir      string `json:"spool_dir"`
+	SpoolMaxBytes int64  `json:"spool_max_bytes"`
+	SpoolMaxAge   string `json:"spool_max_age"`
+	// OverflowPolicy is one of Overflow* values, drop-newest by default.
+	// BlockTimeout is used by block-timeout.
+	OverflowPolicy string `json:"overflow_policy"`
+	BlockTimeout   string `json:"block_timeout"`
+	// SelfReport enables writing the writer stats as go_influx_writer
+	// measurement every SelfReportInterval, BatchInterval by default.
+	SelfReport         bool   `json:"self_report"`
+	SelfReportInterval string `json:"self_report_interval"`
+	// APIVersion selects the http write API: 1 (default) for InfluxDB 1.x
+	// /write, 2 for InfluxDB 2.x /api/v2/write. The 2.x API writes to
+	// Bucket of Org authenticating with Token, Database, User and Password
+	// are not used.
+	APIVersion int    `json:"api_version"`
+	Org        string `json:"org"`
+	Bucket     string `json:"bucket"`
+	Token      string `json:"token"`
+	// Gzip enables compression of http request bodies of at least
+	// GzipMinSize bytes with GzipLevel, gzip.DefaultCompression by default.
+	Gzip        bool `json:"gzip"`
+	GzipLevel   int  `json:"gzip_level"`
+	GzipMinSize int  `json:"gzip_min_size"`
+	// MaxTagValues limits distinct values of every tag key and
+	// MaxSeriesPerMeasurement limits series of every measurement, zero
+	// values mean no limit. Values and series count within
+	// CardinalityWindow since they were seen last. CardinalityAction is one
+	// of Cardinality* values, drop by default. Tags added by the writer
+	// (label and host) are not limited. The values and series are tracked
+	// for every tag key and measurement, which are not limited themselves.
+	MaxTagValues            int    `json:"max_tag_values"`
+	MaxSeriesPerMeasurement int    `json:"max_series_per_measurement"`
+	CardinalityAction       string `json:"cardinality_action"`
+	CardinalityWindow       string `json:"cardinality_window"`
+	// FailoverEndpoints are standby endpoints in order of preference after
+	// Endpoint. Batches go to the first healthy endpoint, an endpoint which
+	// fails a write is skipped for FailoverCooldown, 30s by default, then it
+	// is used again once it responds to ping.
+	FailoverEndpoints []string `json:"failover_endpoints"`
+	FailoverCooldown  string   `json:"failover_cooldown"`
+	// RetentionPolicy and WriteConsistency (any, one, quorum or all) are
+	// used for the batches, empty values mean the server defaults. Routes
+	// send metrics to other databases and retention policies by the
+	// measurement, metrics implementing DatabaseRouter or RetentionPolicy
+	// choose them on their own. Routing isn't supported over udp.
+	RetentionPolicy  string  `json:"retention_policy"`
+	WriteConsistency string  `json:"write_consistency"`
+	Routes           []Route `json:"routes"`
+	// Sink replaces the client created from Endpoint and the transport
+	// settings, the batches are written to it instead
+	Sink Sink `json:"-"`
+	// ErrorHandler receives errors of the background processing, DefaultErrorHandler by default
+	ErrorHandler ErrorHandler `json:"-"`
+}
+
+// ConfigError lists all problems found by Config.Validate
+type ConfigError []string
+
+func (e ConfigError) Error() string {
+	return "influx: invalid config: " + strings.Join(e, "; ")
+}
+
+// Validate checks the config and returns ConfigError describing every
+// invalid field. Omitted fields are valid, NewWriter uses defaults for them.
+func (cfg Config) Validate() error {
+	var problems ConfigError
+	addf := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	var endpoints []string
+	if cfg.Sink == nil {
+		endpoints = cfg.endpoints()
+	}
+	for _, endpoint := range endpoints {
+		switch cfg.Protocol {
+		case "", ProtocolHTTP:
+			if u, err := url.Parse(endpoint); err != nil {
+				addf("endpoint `%s`: %v", endpoint, err)
+			} else if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				addf("endpoint `%s` must be http(s)://host:port", endpoint)
+			}
+		case ProtocolUDP:
+			if _, _, err := net.SplitHostPort(endpoint); err != nil {
+				addf("endpoint `%s` must be host:port: %v", endpoint, err)
+			}
+		}
+	}
+	switch cfg.Protocol {
+	case "", ProtocolHTTP, ProtocolUDP:
+	default:
+		addf("unknown protocol `%s`", cfg.Protocol)
+	}
+
+	// the client encodes microseconds only as "u" and creates batches only with "us"
+	switch cfg.Precision {
+	case "", "ns", "ms", "s", "m", "h":
+	default:
+		addf("precision `%s` must be one of ns, ms, s, m, h", cfg.Precision)
+	}
+
+	switch cfg.APIVersion {
+	case 0, 1:
+	case 2:
+		if cfg.Protocol == ProtocolUDP {
+			addf("api_version 2 isn't supported over udp")
+		}
+		if cfg.Org == "" {
+			addf("org is required for api_version 2")
+		}
+		if cfg.Bucket == "" {
+			addf("bucket is required for api_version 2")
+		}
+		if _, ok := v2Precisions[cfg.Precision]; !ok {
+			addf("precision `%s` isn't supported by api_version 2", cfg.Precision)
+		}
+	default:
+		addf("unknown api_version %d", cfg.APIVersion)
+	}
+
+	durations := []struct {
+		name, value string
+	}{
+		{"batch_interval", cfg.BatchInterval},
+		{"retry_base_backoff", cfg.RetryBaseBackoff},
+		{"retry_max_backoff", cfg.RetryMaxBackoff},
+		{"spool_max_age", cfg.SpoolMaxAge},
+		{"block_timeout", cfg.BlockTimeout},
+		{"self_report_interval", cfg.SelfReportInterval},
+		{"cardinality_window", cfg.CardinalityWindow},
+		{"failover_cooldown", cfg.FailoverCooldown},
+		{"write_timeout", cfg.WriteTimeout},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		if v, err := time.ParseDuration(d.value); err != nil {
+			addf("%s: %v", d.name, err)
+		} else if v <= 0 {
+			addf("%s `%s` must be positive", d.name, d.value)
+		}
+	}
+
+	counts := []struct {
+		name  string
+		value int64
+	}{
+		{"batch_count", int64(cfg.BatchCount)},
+		{"worker_count", int64(cfg.WorkerCount)},
+		{"udp_payload_size", int64(cfg.UDPPayloadSize)},
+		{"retry_max_attempts", int64(cfg.RetryMaxAttempts)},
+		{"spool_max_bytes", cfg.SpoolMaxBytes},
+		{"gzip_min_size", int64(cfg.GzipMinSize)},
+		{"max_tag_values", int64(cfg.MaxTagValues)},
+		{"max_series_per_measurement", int64(cfg.MaxSeriesPerMeasurement)},
+	}
+	for _, c := range counts {
+		if c.value < 0 {
+			addf("%s %d must not be negative", c.name, c.value)
+		}
+	}
+	if cfg.RetryJitter < 0 || cfg.RetryJitter > 1 {
+		addf("retry_jitter %v must be in [0, 1]", cfg.RetryJitter)
+	}
+
+	if cfg.Gzip && cfg.Protocol == ProtocolUDP {
+		addf("gzip isn't supported over udp")
+	}
+	if cfg.GzipLevel < gzip.HuffmanOnly || cfg.GzipLevel > gzip.BestCompression {
+		addf("gzip_level %d must be in [%d, %d]", cfg.GzipLevel, gzip.HuffmanOnly, gzip.BestCompression)
+	}
+
+	switch cfg.OverflowPolicy {
+	case "", OverflowDropNewest, OverflowDropOldest, OverflowBlock, OverflowBlockTimeout:
+	default:
+		addf("unknown overflow_policy `%s`", cfg.OverflowPolicy)
+	}
+
+	switch cfg.WriteConsistency {
+	case "", "any", "one", "quorum", "all":
+	default:
+		addf("unknown write_consistency `%s`", cfg.WriteConsistency)
+	}
+	if len(cfg.Routes) > 0 && cfg.Protocol == ProtocolUDP {
+		addf("routes aren't supported over udp")
+	}
+	for _, r := range cfg.Routes {
+		if _, err := path.Match(r.Pattern, ""); err != nil || r.Pattern == "" {
+			addf("route pattern `%s` is invalid", r.Pattern)
+		}
+	}
+
+	switch cfg.CardinalityAction {
+	case "", CardinalityDrop, CardinalityOther, CardinalityField:
+	default:
+		addf("unknown cardinality_action `%s`", cfg.CardinalityAction)
+	}
+
+	if len(problems) > 0 {
+		return problems
+	}
+	return nil
+}
+
+// endpoints returns Endpoint followed by FailoverEndpoints
+func (cfg Config) endpoints() []string {
+	return append([]string{cfg.Endpoint}, cfg.FailoverEndpoints...)
+}
+
+// withDefaults returns the config with omitted fields set to the defaults
+func (cfg Config) withDefaults() Config {
+	if cfg.Protocol == "" {
+		cfg.Protocol = ProtocolHTTP
+	}
+	if cfg.APIVersion == 0 {
+		cfg.APIVersion = 1
+	}
+	if cfg.Precision == "" {
+		cfg.Precision = DefaultPrecision
+	}
+	if cfg.BatchInterval == "" {
+		cfg.BatchInterval = DefaultBatchInterval
+	}
+	if cfg.BatchCount == 0 {
+		cfg.BatchCount = DefaultBatchCount
+	}
+	if cfg.WorkerCount == 0 {
+		cfg.WorkerCount = DefaultWorkerCount
+	}
+	if cfg.Protocol == ProtocolUDP && cfg.UDPPayloadSize == 0 {
+		cfg.UDPPayloadSize = client.UDPPayloadSize
+	}
+	if cfg.RetryBaseBackoff == "" {
+		cfg.RetryBaseBackoff = defaultRetryBaseBackoff.String()
+	}
+	if cfg.RetryMaxBackoff == "" {
+		cfg.RetryMaxBackoff = defaultRetryMaxBackoff.String()
+	}
+	if cfg.OverflowPolicy == "" {
+		cfg.OverflowPolicy = OverflowDropNewest
+	}
+	if cfg.BlockTimeout == "" {
+		cfg.BlockTimeout = DefaultBlockTimeout
+	}
+	if cfg.SelfReportInterval == "" {
+		cfg.SelfReportInterval = cfg.BatchInterval
+	}
+	if cfg.FailoverCooldown == "" {
+		cfg.FailoverCooldown = DefaultFailoverCooldown
+	}
+	if cfg.WriteTimeout == "" {
+		cfg.WriteTimeout = DefaultWriteTimeout
+	}
+	if cfg.CardinalityAction == "" {
+		cfg.CardinalityAction = CardinalityDrop
+	}
+	if cfg.CardinalityWindow == "" {
+		cfg.CardinalityWindow = DefaultCardinalityWindow
+	}
+	return cfg
+}
@@ -0,0 +1,162 @@
+package influx
+
+import (
+	"fmt"
+	"log"
+)
+
+// ErrorHandler receives errors which happen in the background and can't be
+// returned to the caller. Values are one of the error types of this package
+// (DroppedMetricError, PointError, WriteError, SpoolError, DuplicateMetric)
+// or a plain error for unexpected failures.
+type ErrorHandler interface {
+	HandleError(err error)
+}
+
+// ErrorHandlerFunc adapts an ordinary function to the ErrorHandler interface.
+type ErrorHandlerFunc func(err error)
+
+// HandleError calls f(err).
+func (f ErrorHandlerFunc) HandleError(err error) {
+	f(err)
+}
+
+// NopErrorHandler ignores all errors.
+type NopErrorHandler struct{}
+
+// HandleError does nothing.
+func (NopErrorHandler) HandleError(error) {}
+
+// StdErrorHandler writes errors to a standard logger prefixed with a level,
+// [WARN] for recoverable problems and [ERROR] for lost data. The standard
+// log package output is used when Logger is nil.
+type StdErrorHandler struct {
+	Logger *log.Logger
+}
+
+// HandleError logs the error.
+func (h StdErrorHandler) HandleError(err error) {
+	level := "ERROR"
+	switch e := err.(type) {
+	case *DroppedMetricError:
+		level = "WARN"
+	case *WriteError:
+		if e.Retry || e.Spooled {
+			level = "WARN"
+		}
+	case *SpoolError:
+		if e.Op == "replay" {
+			level = "WARN"
+		}
+	}
+	if h.Logger != nil {
+		h.Logger.Printf("[%s] %v", level, err)
+		return
+	}
+	log.Printf("[%s] %v", level, err)
+}
+
+// DefaultErrorHandler receives errors of registries and runtime stats, and of
+// writers which don't have Config.ErrorHandler. It should be replaced before
+// the package is used.
+var DefaultErrorHandler ErrorHandler = StdErrorHandler{}
+
+// DroppedMetricError is reported when a metric is discarded because the queue is full.
+type DroppedMetricError struct {
+	Metric interface{}
+	// QueueLen is the queue length at the moment
+	QueueLen int
+	// Oldest is set when the oldest queued metric was discarded in favour of a new one
+	Oldest bool
+	// Err is ErrQueueFull or the error of the context which ended waiting
+	Err error
+}
+
+func (e *DroppedMetricError) Error() string {
+	if e.Oldest {
+		return fmt.Sprintf("influx: discarded oldest message, queue is full %d", e.QueueLen)
+	}
+	return fmt.Sprintf("influx: discarded message, queue is full %d: %v", e.QueueLen, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *DroppedMetricError) Unwrap() error {
+	return e.Err
+}
+
+// PointError is reported when a metric can't be converted to a point.
+type PointError struct {
+	Metric interface{}
+	Err    error
+}
+
+func (e *PointError) Error() string {
+	return fmt.Sprintf("influx: can't create point from %v: %v", e.Metric, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *PointError) Unwrap() error {
+	return e.Err
+}
+
+// WriteError is reported when a batch write fails.
+type WriteError struct {
+	// Points is the size of the batch
+	Points int
+	// Attempts is the number of the writes made so far
+	Attempts int
+	// Retry is set when the write is going to be repeated
+	Retry bool
+	// Spooled is set when the batch was saved to the spool
+	Spooled bool
+	Err     error
+}
+
+func (e *WriteError) Error() string {
+	switch {
+	case e.Retry:
+		return fmt.Sprintf("influx: can't write %d points, attempt %d: %v", e.Points, e.Attempts, e.Err)
+	case e.Spooled:
+		return fmt.Sprintf("influx: can't write %d points after %d attempts, spooled: %v", e.Points, e.Attempts, e.Err)
+	}
+	return fmt.Sprintf("influx: can't write %d points after %d attempts, dropped: %v", e.Points, e.Attempts, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
+
+// SpoolError is reported when the spool fails to store, replay or remove a segment.
+type SpoolError struct {
+	Op      string
+	Segment string
+	Err     error
+}
+
+func (e *SpoolError) Error() string {
+	return fmt.Sprintf("influx: spool %s %s: %v", e.Op, e.Segment, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *SpoolError) Unwrap() error {
+	return e.Err
+}
+
+// ShutdownError is returned by Writer.Shutdown when the context is done
+// before all metrics are written.
+type ShutdownError struct {
+	// Abandoned is the number of points which were not written, it is
+	// approximate when a worker was still in a write
+	Abandoned int64
+	Err       error
+}
+
+func (e *ShutdownError) Error() string {
+	return fmt.Sprintf("influx: shutdown abandoned %d points: %v", e.Abandoned, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ShutdownError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,73 @@
+package influx
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+)
+
+const ewmaTickSeconds = 5.0
+
+// EWMA continuously calculates an exponentially-weighted moving average
+// based on an outside source of clock ticks, one tick every 5 seconds.
+type EWMA interface {
+	Rate() float64
+	Tick()
+	Update(int64)
+}
+
+// NewEWMA constructs a new EWMA with the given alpha.
+func NewEWMA(alpha float64) EWMA {
+	return &StandardEWMA{alpha: alpha}
+}
+
+// NewEWMA1 constructs a new EWMA for a one-minute moving average.
+func NewEWMA1() EWMA {
+	return NewEWMA(1 - math.Exp(-ewmaTickSeconds/60.0/1))
+}
+
+// NewEWMA5 constructs a new EWMA for a five-minute moving average.
+func NewEWMA5() EWMA {
+	return NewEWMA(1 - math.Exp(-ewmaTickSeconds/60.0/5))
+}
+
+// NewEWMA15 constructs a new EWMA for a fifteen-minute moving average.
+func NewEWMA15() EWMA {
+	return NewEWMA(1 - math.Exp(-ewmaTickSeconds/60.0/15))
+}
+
+// StandardEWMA is the standard implementation of an EWMA and tracks the number
+// of uncounted events and processes them on each tick.
+type StandardEWMA struct {
+	uncounted int64 // first for atomic alignment
+	alpha     float64
+	mutex     sync.Mutex
+	rate      float64
+	init      bool
+}
+
+// Rate returns the moving average rate of events per second.
+func (a *StandardEWMA) Rate() float64 {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.rate
+}
+
+// Tick ticks the clock to update the moving average. It assumes it is called
+// every five seconds.
+func (a *StandardEWMA) Tick() {
+	instantRate := float64(atomic.SwapInt64(&a.uncounted, 0)) / ewmaTickSeconds
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.init {
+		a.rate += a.alpha * (instantRate - a.rate)
+	} else {
+		a.init = true
+		a.rate = instantRate
+	}
+}
+
+// Update adds n uncounted events.
+func (a *StandardEWMA) Update(n int64) {
+	atomic.AddInt64(&a.uncounted, n)
+}
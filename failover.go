@@ -0,0 +1,141 @@
+package influx
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb/client/v2"
+)
+
+// failoverPingTimeout limits the ping of an endpoint coming back from the cooldown
+const failoverPingTimeout = 5 * time.Second
+
+// errNoEndpoint is returned when all endpoints are cooling down
+var errNoEndpoint = errors.New("influx: all endpoints are failing")
+
+// failoverClient writes to the first healthy of the endpoints ordered by
+// preference. An endpoint failing with a retryable error cools down, after
+// the cooldown it has to answer ping before it's written again, so the
+// writes fail back to the primary once it recovers.
+type failoverClient struct {
+	endpoints []*failoverEndpoint
+	cooldown  time.Duration
+	mutex     sync.Mutex // protects active and the endpoint state
+	active    int
+	now       func() time.Time
+}
+
+type failoverEndpoint struct {
+	url     string
+	client  client.Client
+	failed  time.Time // zero for a healthy endpoint
+	probing bool      // a write path is pinging it after the cooldown
+}
+
+func newFailoverClient(cfg Config) (*failoverClient, error) {
+	c := &failoverClient{
+		cooldown: mustParseDuration(cfg.FailoverCooldown),
+		now:      time.Now,
+	}
+	for _, endpoint := range cfg.endpoints() {
+		endpointCfg := cfg
+		endpointCfg.Endpoint = endpoint
+		endpointCfg.FailoverEndpoints = nil
+		ec, err := newClient(endpointCfg)
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+		c.endpoints = append(c.endpoints, &failoverEndpoint{url: endpoint, client: ec})
+	}
+	return c, nil
+}
+
+// Active returns the index and the url of the endpoint written last
+func (c *failoverClient) Active() (int, string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.active, c.endpoints[c.active].url
+}
+
+// usable reports whether the endpoint can be written, an endpoint after
+// the cooldown is pinged first by one writer while the others skip it
+func (c *failoverClient) usable(e *failoverEndpoint) bool {
+	c.mutex.Lock()
+	if e.failed.IsZero() {
+		c.mutex.Unlock()
+		return true
+	}
+	if e.probing || c.now().Sub(e.failed) < c.cooldown {
+		c.mutex.Unlock()
+		return false
+	}
+	e.probing = true
+	c.mutex.Unlock()
+
+	_, _, err := e.client.Ping(failoverPingTimeout)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	e.probing = false
+	if err != nil {
+		e.failed = c.now()
+		return false
+	}
+	e.failed = time.Time{}
+	return true
+}
+
+func (c *failoverClient) setFailed(e *failoverEndpoint, t time.Time) {
+	c.mutex.Lock()
+	e.failed = t
+	c.mutex.Unlock()
+}
+
+func (c *failoverClient) Write(bp client.BatchPoints) error {
+	err := errNoEndpoint
+	for i, e := range c.endpoints {
+		if !c.usable(e) {
+			continue
+		}
+		if err = e.client.Write(bp); err == nil {
+			c.mutex.Lock()
+			e.failed = time.Time{}
+			c.active = i
+			c.mutex.Unlock()
+			return nil
+		}
+		if !isRetryable(err) {
+			return err // the batch would fail on any endpoint
+		}
+		c.setFailed(e, c.now())
+	}
+	return err
+}
+
+// Ping pings the endpoints in order and returns the first success
+func (c *failoverClient) Ping(timeout time.Duration) (time.Duration, string, error) {
+	var err error
+	for _, e := range c.endpoints {
+		var d time.Duration
+		var version string
+		if d, version, err = e.client.Ping(timeout); err == nil {
+			return d, version, nil
+		}
+	}
+	return 0, "", err
+}
+
+func (c *failoverClient) Query(q client.Query) (*client.Response, error) {
+	return c.endpoints[0].client.Query(q)
+}
+
+func (c *failoverClient) Close() error {
+	var ret error
+	for _, e := range c.endpoints {
+		if err := e.client.Close(); err != nil && ret == nil {
+			ret = err
+		}
+	}
+	return ret
+}
@@ -0,0 +1,103 @@
+package influx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailover(t *testing.T) {
+	var primaryDown int32 = 1
+	var primaryWrites, standbyWrites int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&primaryDown) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path == "/write" {
+			atomic.AddInt32(&primaryWrites, 1)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer primary.Close()
+	standby := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&standbyWrites, 1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer standby.Close()
+
+	w, err := NewWriter(Config{
+		Endpoint:          primary.URL,
+		FailoverEndpoints: []string{standby.URL},
+		FailoverCooldown:  "50ms",
+		BatchInterval:     "1h",
+		RetryMaxAttempts:  1,
+		ErrorHandler:      NopErrorHandler{},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	write := func() {
+		w.Write(SimpleMetric{Name: "failover", ValuesMap: map[string]interface{}{"value": 1}})
+		assert.NoError(t, w.Flush(context.Background()))
+	}
+
+	write()
+	assert.EqualValues(t, 1, atomic.LoadInt32(&standbyWrites))
+	assert.Equal(t, standby.URL, w.Stats().ActiveEndpoint)
+
+	// the primary is in the cooldown
+	atomic.StoreInt32(&primaryDown, 0)
+	write()
+	assert.EqualValues(t, 2, atomic.LoadInt32(&standbyWrites))
+
+	time.Sleep(60 * time.Millisecond)
+	write()
+	assert.EqualValues(t, 1, atomic.LoadInt32(&primaryWrites))
+	assert.Equal(t, primary.URL, w.Stats().ActiveEndpoint)
+	assert.EqualValues(t, 0, w.Stats().BatchesFailed)
+	assert.NoError(t, w.Close())
+
+	_, err = NewWriter(Config{Endpoint: primary.URL, FailoverEndpoints: []string{"standby:8086"}})
+	assert.Error(t, err)
+}
+
+func TestFailoverSingleProbe(t *testing.T) {
+	var pings int32
+	release := make(chan struct{})
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ping" {
+			atomic.AddInt32(&pings, 1)
+			<-release
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer primary.Close()
+
+	c, err := newFailoverClient(Config{Endpoint: primary.URL, FailoverEndpoints: []string{"http://standby:8086"}}.withDefaults())
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer c.Close()
+	e := c.endpoints[0]
+	c.setFailed(e, time.Now().Add(-time.Hour))
+
+	probed := make(chan bool)
+	go func() { probed <- c.usable(e) }()
+	for atomic.LoadInt32(&pings) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// the other writers skip the endpoint while it's pinged
+	for i := 0; i < 5; i++ {
+		assert.False(t, c.usable(e))
+	}
+	close(release)
+	assert.True(t, <-probed)
+	assert.True(t, c.usable(e))
+	assert.EqualValues(t, 1, atomic.LoadInt32(&pings))
+}
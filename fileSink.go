@@ -0,0 +1,326 @@
+package influx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb/client/v2"
+)
+
+const (
+	fileSinkExt    = ".lp"
+	fileSinkGzExt  = ".gz"
+	fileSinkPrefix = "metrics"
+)
+
+// FileSinkConfig configures a FileSink, durations are strings accepted by
+// time.ParseDuration.
+type FileSinkConfig struct {
+	// Dir is created if it doesn't exist
+	Dir string `json:"dir"`
+	// Prefix starts the file names, "metrics" by default
+	Prefix string `json:"prefix"`
+	// MaxSize and MaxAge rotate the file when a batch would make it larger
+	// or it's older, zero values mean no limit
+	MaxSize int64  `json:"max_size"`
+	MaxAge  string `json:"max_age"`
+	// Gzip compresses rotated files
+	Gzip bool `json:"gzip"`
+	// MaxFiles is the number of rotated files to keep, the oldest ones are
+	// removed, zero keeps all of them
+	MaxFiles int `json:"max_files"`
+	// ErrorHandler receives errors of compression and retention of rotated
+	// files, DefaultErrorHandler by default
+	ErrorHandler ErrorHandler `json:"-"`
+}
+
+// FileSink appends batches as line protocol to files in a directory, so
+// metrics can be recorded without connectivity. Files have the context
+// headers of `influx -import` (use -compressed for gzipped files) and can be
+// written to another sink with ReplayFiles. `influx -import` ignores the
+// precision header, pass the precision of the writer with -precision. Files
+// are rotated only by writes, a file written before a restart is treated as
+// rotated.
+type FileSink struct {
+	dir      string
+	prefix   string
+	maxSize  int64
+	maxAge   time.Duration
+	gzip     bool
+	maxFiles int
+
+	errorHandler ErrorHandler
+
+	mutex   sync.Mutex // protects the current file
+	file    *os.File
+	name    string
+	size    int64
+	created time.Time
+
+	filesMutex sync.Mutex // serializes compression and retention
+	wg         sync.WaitGroup
+}
+
+// NewFileSink creates the directory and compresses files left by a previous run.
+func NewFileSink(cfg FileSinkConfig) (*FileSink, error) {
+	var problems ConfigError
+	if cfg.Dir == "" {
+		problems = append(problems, "dir is required")
+	}
+	var maxAge time.Duration
+	if cfg.MaxAge != "" {
+		var err error
+		if maxAge, err = time.ParseDuration(cfg.MaxAge); err != nil {
+			problems = append(problems, fmt.Sprintf("max_age: %v", err))
+		} else if maxAge <= 0 {
+			problems = append(problems, fmt.Sprintf("max_age `%s` must be positive", cfg.MaxAge))
+		}
+	}
+	if cfg.MaxSize < 0 {
+		problems = append(problems, fmt.Sprintf("max_size %d must not be negative", cfg.MaxSize))
+	}
+	if cfg.MaxFiles < 0 {
+		problems = append(problems, fmt.Sprintf("max_files %d must not be negative", cfg.MaxFiles))
+	}
+	if len(problems) > 0 {
+		return nil, problems
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = fileSinkPrefix
+	}
+	if cfg.ErrorHandler == nil {
+		cfg.ErrorHandler = DefaultErrorHandler
+	}
+	if err := os.MkdirAll(cfg.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	s := &FileSink{
+		dir:      cfg.Dir,
+		prefix:   cfg.Prefix,
+		maxSize:  cfg.MaxSize,
+		maxAge:   maxAge,
+		gzip:     cfg.Gzip,
+		maxFiles: cfg.MaxFiles,
+
+		errorHandler: cfg.ErrorHandler,
+	}
+	s.wg.Add(1)
+	go s.finish()
+	return s, nil
+}
+
+// WriteBatch appends the batch to the current file, rotating it first if
+// the limits are exceeded.
+func (s *FileSink) WriteBatch(ctx context.Context, bp client.BatchPoints) error {
+	var b bytes.Buffer
+	encodeBatch(&b, bp)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.file != nil && s.size > 0 {
+		if (s.maxSize > 0 && s.size+int64(b.Len()) > s.maxSize) || (s.maxAge > 0 && time.Since(s.created) >= s.maxAge) {
+			if err := s.rotate(); err != nil {
+				return err
+			}
+		}
+	}
+	if s.file == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+	n, err := s.file.Write(b.Bytes())
+	s.size += int64(n)
+	return err
+}
+
+// Rotate closes the current file, the next batch starts a new one.
+func (s *FileSink) Rotate() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.rotate()
+}
+
+// Close closes the current file and waits until rotated files are compressed.
+func (s *FileSink) Close() error {
+	err := s.Rotate()
+	s.wg.Wait()
+	return err
+}
+
+// Rotated returns paths of the rotated files from the oldest to the newest.
+func (s *FileSink) Rotated() ([]string, error) {
+	files, err := s.rotated()
+	if err != nil {
+		return nil, err
+	}
+	for i, name := range files {
+		files[i] = filepath.Join(s.dir, name)
+	}
+	return files, nil
+}
+
+// open starts a new file named after the creation time
+func (s *FileSink) open() error {
+	now := time.Now()
+	name := fmt.Sprintf("%s-%020d%s", s.prefix, now.UnixNano(), fileSinkExt)
+	f, err := os.OpenFile(filepath.Join(s.dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(headerDML); err != nil {
+		f.Close()
+		return err
+	}
+	s.file, s.name, s.size, s.created = f, name, 0, now
+	return nil
+}
+
+// rotate closes the current file and compresses it in the background
+func (s *FileSink) rotate() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file, s.name = nil, ""
+	s.wg.Add(1)
+	go s.finish()
+	return err
+}
+
+// rotated returns names of the rotated files from the oldest to the newest
+func (s *FileSink) rotated() ([]string, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	s.mutex.Lock()
+	current := s.name
+	s.mutex.Unlock()
+
+	var names []string
+	for _, f := range files {
+		name := f.Name()
+		if !f.Mode().IsRegular() || name == current || !strings.HasPrefix(name, s.prefix+"-") {
+			continue
+		}
+		if strings.HasSuffix(name, fileSinkExt) || strings.HasSuffix(name, fileSinkExt+fileSinkGzExt) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// finish compresses the rotated files and removes the ones exceeding maxFiles
+func (s *FileSink) finish() {
+	defer s.wg.Done()
+	s.filesMutex.Lock()
+	defer s.filesMutex.Unlock()
+
+	names, err := s.rotated()
+	if err != nil {
+		s.errorHandler.HandleError(err)
+		return
+	}
+	if s.gzip {
+		for i, name := range names {
+			if !strings.HasSuffix(name, fileSinkExt) {
+				continue
+			}
+			if err := compressFile(filepath.Join(s.dir, name)); err != nil {
+				s.errorHandler.HandleError(fmt.Errorf("influx: compress %s: %v", name, err))
+				continue
+			}
+			names[i] = name + fileSinkGzExt
+		}
+	}
+	if s.maxFiles > 0 && len(names) > s.maxFiles {
+		for _, name := range names[:len(names)-s.maxFiles] {
+			if err := os.Remove(filepath.Join(s.dir, name)); err != nil && !os.IsNotExist(err) {
+				s.errorHandler.HandleError(err)
+			}
+		}
+	}
+}
+
+// compressFile replaces the file with its gzipped copy
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	tmp := name + fileSinkGzExt + ".tmp"
+	dst, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := gzip.NewWriter(dst)
+	if _, err = io.Copy(w, src); err == nil {
+		err = w.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, name+fileSinkGzExt)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(name)
+}
+
+// ReplayFiles writes batches recorded by FileSink to the sink in order of
+// the files, gzipped files are decompressed. It stops at the first error,
+// the files are not removed.
+func ReplayFiles(ctx context.Context, sink Sink, files ...string) error {
+	for _, name := range files {
+		data, err := readFile(name)
+		if err != nil {
+			return err
+		}
+		batches, err := decodeBatches(data)
+		if err != nil {
+			return fmt.Errorf("influx: %s: %v", name, err)
+		}
+		for _, batch := range batches {
+			if err := sink.WriteBatch(ctx, batch); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readFile reads the file decompressing it if its name ends with .gz
+func readFile(name string) ([]byte, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(name, fileSinkGzExt) {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	return ioutil.ReadAll(r)
+}
@@ -0,0 +1,84 @@
+package influx
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/client/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "influx-file")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	_, err = NewFileSink(FileSinkConfig{MaxAge: "soon", MaxFiles: -1})
+	if assert.IsType(t, ConfigError{}, err) {
+		assert.Len(t, err.(ConfigError), 3)
+	}
+
+	sink, err := NewFileSink(FileSinkConfig{Dir: dir, MaxSize: 200, Gzip: true, MaxFiles: 2})
+	if !assert.NoError(t, err) {
+		return
+	}
+	batch := func(i int) client.BatchPoints {
+		bp, _ := client.NewBatchPoints(client.BatchPointsConfig{Database: "pool", RetentionPolicy: "week", Precision: "s"})
+		p, _ := client.NewPoint("shares", map[string]string{"coin": "btc"}, map[string]interface{}{"value": i}, time.Unix(1500000000+int64(i), 0))
+		bp.AddPoint(p)
+		return bp
+	}
+	// a batch takes more than half of MaxSize, so every one starts a new file
+	for i := 0; i < 6; i++ {
+		assert.NoError(t, sink.WriteBatch(context.Background(), batch(i)))
+	}
+	assert.NoError(t, sink.Close())
+
+	files, err := sink.Rotated()
+	assert.NoError(t, err)
+	if !assert.Len(t, files, 2) {
+		return
+	}
+	for _, f := range files {
+		assert.True(t, strings.HasSuffix(f, ".lp.gz"), f)
+	}
+	data, err := readFile(files[0])
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(data), "# DML\n# CONTEXT-DATABASE: pool\n# CONTEXT-RETENTION-POLICY: week\n"), string(data))
+
+	replayed := &testSink{}
+	assert.NoError(t, ReplayFiles(context.Background(), replayed, files...))
+	if assert.Len(t, replayed.batches, 2) {
+		assert.Equal(t, "week", replayed.batches[0].RetentionPolicy())
+		assert.Len(t, replayed.batches[0].Points(), 1)
+		assert.Equal(t, "shares,coin=btc value=4i 1500000004", replayed.batches[0].Points()[0].PrecisionString("s"))
+	}
+
+	// an uncompressed file left by a crash is compressed on start
+	left := filepath.Join(dir, "metrics-00000000000000000001.lp")
+	assert.NoError(t, ioutil.WriteFile(left, []byte(headerDML), 0644))
+	sink, err = NewFileSink(FileSinkConfig{Dir: dir, Gzip: true})
+	if assert.NoError(t, err) {
+		assert.NoError(t, sink.Close())
+		_, err = os.Stat(left + ".gz")
+		assert.NoError(t, err)
+	}
+
+	// compression errors go to the error handler
+	left = filepath.Join(dir, "metrics-00000000000000000002.lp")
+	assert.NoError(t, ioutil.WriteFile(left, []byte(headerDML), 0644))
+	assert.NoError(t, os.Mkdir(left+".gz.tmp", 0755))
+	var handled []error
+	sink, err = NewFileSink(FileSinkConfig{Dir: dir, Gzip: true, ErrorHandler: ErrorHandlerFunc(func(err error) { handled = append(handled, err) })})
+	if assert.NoError(t, err) {
+		assert.NoError(t, sink.Close())
+		assert.Len(t, handled, 1)
+	}
+}
@@ -26,3 +26,21 @@ func (g *standardGauge) Value() int64 {
 func NewGauge() Gauge {
 	return &standardGauge{0}
 }
+
+// FunctionalGauge is a Gauge which computes its value on read from a function.
+type FunctionalGauge struct {
+	value func() int64
+}
+
+// Update does nothing, the value comes from the function.
+func (g FunctionalGauge) Update(int64) {}
+
+// Value returns the gauge's current value.
+func (g FunctionalGauge) Value() int64 {
+	return g.value()
+}
+
+// NewFunctionalGauge constructs a new FunctionalGauge.
+func NewFunctionalGauge(f func() int64) Gauge {
+	return FunctionalGauge{value: f}
+}
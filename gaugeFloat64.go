@@ -0,0 +1,49 @@
+package influx
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+type standardGaugeFloat64 struct {
+	value uint64 // bits of float64
+}
+
+// GaugeFloat64 hold a float64 value that can be set arbitrarily.
+type GaugeFloat64 interface {
+	Update(float64)
+	Value() float64
+}
+
+// Update updates the gauge's value.
+func (g *standardGaugeFloat64) Update(v float64) {
+	atomic.StoreUint64(&g.value, math.Float64bits(v))
+}
+
+// Value returns the gauge's current value.
+func (g *standardGaugeFloat64) Value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&g.value))
+}
+
+// NewGaugeFloat64 constructs a new GaugeFloat64.
+func NewGaugeFloat64() GaugeFloat64 {
+	return &standardGaugeFloat64{0}
+}
+
+// FunctionalGaugeFloat64 is a GaugeFloat64 which computes its value on read from a function.
+type FunctionalGaugeFloat64 struct {
+	value func() float64
+}
+
+// Update does nothing, the value comes from the function.
+func (g FunctionalGaugeFloat64) Update(float64) {}
+
+// Value returns the gauge's current value.
+func (g FunctionalGaugeFloat64) Value() float64 {
+	return g.value()
+}
+
+// NewFunctionalGaugeFloat64 constructs a new FunctionalGaugeFloat64.
+func NewFunctionalGaugeFloat64(f func() float64) GaugeFloat64 {
+	return FunctionalGaugeFloat64{value: f}
+}
@@ -0,0 +1,25 @@
+package influx
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGauges(t *testing.T) {
+	g := NewGaugeFloat64()
+	g.Update(47.5e12)
+	assert.Equal(t, 47.5e12, g.Value())
+
+	clients := 3
+	r := NewRegistry()
+	assert.NoError(t, r.Register("hashrate", g))
+	assert.NoError(t, r.Register("clients", NewFunctionalGauge(func() int64 { return int64(clients) })))
+	assert.NoError(t, r.Register("ratio", NewFunctionalGaugeFloat64(func() float64 { return 1 / float64(clients) })))
+
+	clients = 4
+	values := newRegistryMetric("test", r).Values()
+	assert.Equal(t, 47.5e12, values["hashrate"])
+	assert.Equal(t, int64(4), values["clients"])
+	assert.Equal(t, 0.25, values["ratio"])
+}
@@ -0,0 +1,102 @@
+package influx
+
+import "math"
+
+// Histogram calculates distribution statistics from a series of int64 values.
+type Histogram interface {
+	Clear()
+	Count() int64
+	Max() int64
+	Mean() float64
+	Min() int64
+	Percentile(float64) float64
+	Percentiles([]float64) []float64
+	Sample() Sample
+	StdDev() float64
+	Sum() int64
+	Update(int64)
+	Variance() float64
+}
+
+// reportedPercentiles are flattened into fields of the histogram when it is reported
+var reportedPercentiles = []struct {
+	name string
+	p    float64
+}{
+	{"p50", 0.5},
+	{"p75", 0.75},
+	{"p95", 0.95},
+	{"p99", 0.99},
+	{"p999", 0.999},
+}
+
+// StandardHistogram is the standard implementation of a Histogram and uses a
+// Sample to bound its memory use.
+type StandardHistogram struct {
+	sample Sample
+}
+
+// NewHistogram constructs a new StandardHistogram from a Sample.
+func NewHistogram(s Sample) Histogram {
+	return &StandardHistogram{sample: s}
+}
+
+// Clear clears the histogram and its sample.
+func (h *StandardHistogram) Clear() { h.sample.Clear() }
+
+// Count returns the number of samples recorded since the histogram was last
+// cleared.
+func (h *StandardHistogram) Count() int64 { return h.sample.Count() }
+
+// Max returns the maximum value in the sample.
+func (h *StandardHistogram) Max() int64 { return h.sample.Max() }
+
+// Mean returns the mean of the values in the sample.
+func (h *StandardHistogram) Mean() float64 { return h.sample.Mean() }
+
+// Min returns the minimum value in the sample.
+func (h *StandardHistogram) Min() int64 { return h.sample.Min() }
+
+// Percentile returns an arbitrary percentile of the values in the sample.
+func (h *StandardHistogram) Percentile(p float64) float64 {
+	return h.sample.Percentile(p)
+}
+
+// Percentiles returns a slice of arbitrary percentiles of the values in the
+// sample.
+func (h *StandardHistogram) Percentiles(ps []float64) []float64 {
+	return h.sample.Percentiles(ps)
+}
+
+// Sample returns the Sample underlying the histogram.
+func (h *StandardHistogram) Sample() Sample { return h.sample }
+
+// StdDev returns the standard deviation of the values in the sample.
+func (h *StandardHistogram) StdDev() float64 { return h.sample.StdDev() }
+
+// Sum returns the sum in the sample.
+func (h *StandardHistogram) Sum() int64 { return h.sample.Sum() }
+
+// Update samples a new value.
+func (h *StandardHistogram) Update(v int64) { h.sample.Update(v) }
+
+// Variance returns the variance of the values in the sample.
+func (h *StandardHistogram) Variance() float64 { return h.sample.Variance() }
+
+// histogramValues flattens the histogram statistics into name.count, name.p99 etc fields
+func histogramValues(values map[string]interface{}, name string, h Histogram) {
+	// one copy of the sample keeps the fields consistent
+	s := h.Sample().Values()
+	values[fieldName(name, "count")] = h.Count()
+	values[fieldName(name, "min")] = sampleMin(s)
+	values[fieldName(name, "max")] = sampleMax(s)
+	values[fieldName(name, "mean")] = sampleMean(s)
+	values[fieldName(name, "stddev")] = math.Sqrt(sampleVariance(s))
+	ps := make([]float64, len(reportedPercentiles))
+	for i, p := range reportedPercentiles {
+		ps[i] = p.p
+	}
+	for i, v := range samplePercentiles(s, ps) {
+		values[fieldName(name, reportedPercentiles[i].name)] = v
+	}
+}
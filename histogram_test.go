@@ -0,0 +1,57 @@
+package influx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUniformSampleHistogram(t *testing.T) {
+	h := NewHistogram(NewUniformSample(100))
+	for i := 1; i <= 100; i++ {
+		h.Update(int64(i))
+	}
+	assert.EqualValues(t, 100, h.Count())
+	assert.EqualValues(t, 1, h.Min())
+	assert.EqualValues(t, 100, h.Max())
+	assert.EqualValues(t, 5050, h.Sum())
+	assert.Equal(t, 50.5, h.Mean())
+	assert.InDelta(t, 28.866, h.StdDev(), 0.001)
+	assert.Equal(t, []float64{50.5, 99.99}, h.Percentiles([]float64{0.5, 0.99}))
+
+	for i := 0; i < 1000; i++ {
+		h.Update(int64(i))
+	}
+	assert.EqualValues(t, 1100, h.Count())
+	assert.Equal(t, 100, h.Sample().Size())
+
+	h.Clear()
+	assert.EqualValues(t, 0, h.Count())
+	assert.EqualValues(t, 0, h.Max())
+}
+
+func TestExpDecaySample(t *testing.T) {
+	s := NewExpDecaySample(100, 0.015).(*ExpDecaySample)
+	start := time.Now()
+	for i := 0; i < 1000; i++ {
+		s.update(start.Add(time.Duration(i)*10*time.Second), int64(i))
+	}
+	assert.EqualValues(t, 1000, s.Count())
+	assert.Equal(t, 100, s.Size())
+	// recent values are much more likely to survive, also across the rescales
+	assert.True(t, s.Mean() > 900, "mean %v", s.Mean())
+}
+
+func TestHistogramRegistry(t *testing.T) {
+	r := NewRegistry()
+	h := r.GetOrRegister("latency", func() Histogram { return NewHistogram(NewUniformSample(10)) }).(Histogram)
+	h.Update(10)
+	h.Update(20)
+	values := newRegistryMetric("test", r).Values()
+	assert.Equal(t, int64(2), values["latency.count"])
+	assert.Equal(t, int64(10), values["latency.min"])
+	assert.Equal(t, int64(20), values["latency.max"])
+	assert.Equal(t, 15.0, values["latency.mean"])
+	assert.Equal(t, 20.0, values["latency.p99"])
+}
@@ -0,0 +1,212 @@
+package influx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb/client/v2"
+)
+
+const userAgent = "go-influx"
+
+// HTTPError is returned when influx responds to a write with an error status.
+type HTTPError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("influx: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// newHTTPError reads the message from 1.x {"error":...} or 2.x {"message":...} body
+func newHTTPError(resp *http.Response) *HTTPError {
+	body, _ := ioutil.ReadAll(resp.Body)
+	var msg struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+	}
+	ret := &HTTPError{StatusCode: resp.StatusCode, Message: string(bytes.TrimSpace(body))}
+	if json.Unmarshal(body, &msg) == nil {
+		if msg.Message != "" {
+			ret.Message = msg.Message
+		} else if msg.Error != "" {
+			ret.Message = msg.Error
+		}
+	}
+	return ret
+}
+
+// v2Precisions maps the batch precision to the precision of 2.x write API
+var v2Precisions = map[string]string{
+	"":   "ns",
+	"ns": "ns",
+	"ms": "ms",
+	"s":  "s",
+}
+
+// httpClient writes batches with either 1.x /write or 2.x /api/v2/write API.
+// For 2.x database of the batch is the bucket, batches with a retention
+// policy go to "database/rp" bucket as 1.x compatibility mapping does.
+// Bodies of at least gzipMinSize bytes are gzip-encoded when gzip is enabled.
+type httpClient struct {
+	url         url.URL
+	apiVersion  int
+	username    string
+	password    string
+	org         string
+	token       string
+	gzip        bool
+	gzipMinSize int
+	gzipPool    sync.Pool
+	httpClient  *http.Client
+}
+
+func newHTTPClient(cfg Config) (client.Client, error) {
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	var timeout time.Duration
+	if cfg.WriteTimeout != "" {
+		timeout = mustParseDuration(cfg.WriteTimeout)
+	}
+	level := cfg.GzipLevel
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	c := &httpClient{
+		url:         *u,
+		apiVersion:  cfg.APIVersion,
+		username:    cfg.User,
+		password:    cfg.Password,
+		org:         cfg.Org,
+		token:       cfg.Token,
+		gzip:        cfg.Gzip,
+		gzipMinSize: cfg.GzipMinSize,
+		httpClient: &http.Client{
+			Transport: &http.Transport{Proxy: http.ProxyFromEnvironment},
+			Timeout:   timeout,
+		},
+	}
+	c.gzipPool.New = func() interface{} {
+		w, _ := gzip.NewWriterLevel(nil, level) // level is validated by Config.Validate
+		return w
+	}
+	return c, nil
+}
+
+func (c *httpClient) Write(bp client.BatchPoints) error {
+	var b bytes.Buffer
+	for _, p := range bp.Points() {
+		if p == nil {
+			continue
+		}
+		b.WriteString(p.PrecisionString(bp.Precision()))
+		b.WriteByte('\n')
+	}
+
+	u := c.url
+	params := url.Values{}
+	if c.apiVersion == 2 {
+		bucket := bp.Database()
+		if rp := bp.RetentionPolicy(); rp != "" {
+			bucket += "/" + rp
+		}
+		u.Path = path.Join(u.Path, "api/v2/write")
+		params.Set("org", c.org)
+		params.Set("bucket", bucket)
+		params.Set("precision", v2Precisions[bp.Precision()])
+	} else {
+		u.Path = path.Join(u.Path, "write")
+		params.Set("db", bp.Database())
+		params.Set("rp", bp.RetentionPolicy())
+		params.Set("precision", bp.Precision())
+		params.Set("consistency", bp.WriteConsistency())
+	}
+	u.RawQuery = params.Encode()
+
+	body := &b
+	compressed := c.gzip && b.Len() >= c.gzipMinSize
+	if compressed {
+		var err error
+		if body, err = c.compress(b.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest("POST", u.String(), body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	req.Header.Set("User-Agent", userAgent)
+	if compressed {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Token "+c.token)
+	} else if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return newHTTPError(resp)
+	}
+	return nil
+}
+
+func (c *httpClient) compress(data []byte) (*bytes.Buffer, error) {
+	var b bytes.Buffer
+	w := c.gzipPool.Get().(*gzip.Writer)
+	defer c.gzipPool.Put(w)
+	w.Reset(&b)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// Ping checks /ping endpoint, which both 1.x and 2.x serve without authentication
+func (c *httpClient) Ping(timeout time.Duration) (time.Duration, string, error) {
+	now := time.Now()
+	u := c.url
+	u.Path = path.Join(u.Path, "ping")
+	httpClient := *c.httpClient
+	httpClient.Timeout = timeout
+	resp, err := httpClient.Get(u.String())
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		return 0, "", newHTTPError(resp)
+	}
+	return time.Since(now), resp.Header.Get("X-Influxdb-Version"), nil
+}
+
+func (c *httpClient) Query(q client.Query) (*client.Response, error) {
+	return nil, fmt.Errorf("Querying isn't supported by the writer client")
+}
+
+func (c *httpClient) Close() error {
+	if t, ok := c.httpClient.Transport.(*http.Transport); ok {
+		t.CloseIdleConnections()
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package influx
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/client/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestV2Write(t *testing.T) {
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ping" {
+			w.Header().Set("X-Influxdb-Version", "2.7.1")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		assert.Equal(t, "/api/v2/write", r.URL.Path)
+		assert.Equal(t, "pool", r.URL.Query().Get("org"))
+		assert.Equal(t, "shares", r.URL.Query().Get("bucket"))
+		assert.Equal(t, "ms", r.URL.Query().Get("precision"))
+		assert.Equal(t, "Token secret", r.Header.Get("Authorization"))
+		b, _ := ioutil.ReadAll(r.Body)
+		body = string(b)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	w, err := NewWriter(Config{
+		Endpoint:   srv.URL,
+		APIVersion: 2,
+		Org:        "pool",
+		Bucket:     "shares",
+		Token:      "secret",
+		Precision:  "ms",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, version, err := w.sink.(pinger).Ping(time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "2.7.1", version)
+
+	w.Write(SimpleMetric{Name: "share", ValuesMap: map[string]interface{}{"diff": 2.5}, CreateTime: time.Unix(1500000000, 0)})
+	assert.NoError(t, w.Close())
+	assert.Equal(t, "share diff=2.5 1500000000000\n", body)
+}
+
+func TestV2WriteError(t *testing.T) {
+	status := http.StatusBadRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(`{"code":"invalid","message":"unable to parse points"}`))
+	}))
+	defer srv.Close()
+
+	c, err := newHTTPClient(Config{Endpoint: srv.URL, APIVersion: 2, Org: "pool"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	err = c.Write(newBatch("shares", "s"))
+	if assert.IsType(t, &HTTPError{}, err) {
+		assert.Equal(t, "unable to parse points", err.(*HTTPError).Message)
+	}
+	assert.False(t, isRetryable(err))
+
+	status = http.StatusServiceUnavailable
+	assert.True(t, isRetryable(c.Write(newBatch("shares", "s"))))
+}
+
+func TestGzipWrite(t *testing.T) {
+	var encodings []string
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/write", r.URL.Path)
+		assert.Equal(t, "pool", r.URL.Query().Get("db"))
+		encodings = append(encodings, r.Header.Get("Content-Encoding"))
+		var body io.Reader = r.Body
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if !assert.NoError(t, err) {
+				return
+			}
+			body = zr
+		}
+		b, _ := ioutil.ReadAll(body)
+		bodies = append(bodies, string(b))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c, err := newHTTPClient(Config{Endpoint: srv.URL, Gzip: true, GzipLevel: gzip.BestSpeed, GzipMinSize: 64})
+	if !assert.NoError(t, err) {
+		return
+	}
+	small := benchmarkBatch(1)
+	large := benchmarkBatch(10)
+	assert.NoError(t, c.Write(small))
+	assert.NoError(t, c.Write(large))
+	assert.Equal(t, []string{"", "gzip"}, encodings)
+	if assert.Len(t, bodies, 2) {
+		assert.Equal(t, 10, strings.Count(bodies[1], "\n"))
+		assert.True(t, strings.HasPrefix(bodies[1], "share,worker=rig0 diff=1024,valid=true 1500000000000000000\n"), bodies[1])
+	}
+}
+
+func benchmarkBatch(n int) client.BatchPoints {
+	batch := newBatch("pool", "ns")
+	for i := 0; i < n; i++ {
+		point, _ := newPoint(nil, SimpleMetric{
+			Name:       "share",
+			TagsMap:    map[string]string{"worker": fmt.Sprintf("rig%d", i%50)},
+			ValuesMap:  map[string]interface{}{"diff": 1024.0, "valid": true},
+			CreateTime: time.Unix(1500000000, int64(i)),
+		})
+		batch.AddPoint(point)
+	}
+	return batch
+}
+
+func BenchmarkHTTPWrite(b *testing.B) {
+	var received int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := io.Copy(ioutil.Discard, r.Body)
+		atomic.AddInt64(&received, n)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	batch := benchmarkBatch(5000)
+	var size int64
+	for _, p := range batch.Points() {
+		size += int64(len(p.String()) + 1)
+	}
+	for _, bc := range []struct {
+		name string
+		cfg  Config
+	}{
+		{"plain", Config{Endpoint: srv.URL}},
+		{"gzip-speed", Config{Endpoint: srv.URL, Gzip: true, GzipLevel: gzip.BestSpeed}},
+		{"gzip-default", Config{Endpoint: srv.URL, Gzip: true}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			c, err := newHTTPClient(bc.cfg)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer c.Close()
+			atomic.StoreInt64(&received, 0)
+			b.SetBytes(size) // line protocol before compression
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if err := c.Write(batch); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.Logf("%d wire bytes/op", atomic.LoadInt64(&received)/int64(b.N))
+		})
+	}
+}
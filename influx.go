@@ -1,34 +1,35 @@
 package influx
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
 	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/influxdata/influxdb/client/v2"
 )
 
-const forceChanLen = 30
+var (
+	// ErrQueueFull is returned by WriteContext when the metric was discarded because the queue is full
+	ErrQueueFull = errors.New("influx: queue is full")
+	// ErrWriterClosed is returned by WriteContext after the writer is closed
+	ErrWriterClosed = errors.New("influx: writer is closed")
+)
 
-//Config represents config values stored in json
-type Config struct {
-	Endpoint      string `json:"endpoint"`
-	Database      string `json:"database"`
-	User          string `json:"user"`
-	Password      string `json:"password"`
-	Host          string `json:"host"`
-	Label         string `json:"label"`
-	BatchInterval string `json:"batch_interval"`
-	BatchCount    int    `json:"batch_count"`
-	WorkerCount   int    `json:"worker_count"`
-	Precision     string `json:"precision"`
-}
+// shutdownGrace is how long Shutdown waits for the workers after its deadline
+const shutdownGrace = 100 * time.Millisecond
 
 //Writer accept messages and write them to influx in the background
 type Writer struct {
+	pending       int64 // points in the batches of workers, first for atomic alignment
 	wg            sync.WaitGroup
-	client        client.Client
+	sink          Sink
+	failover      *failoverClient // nil without failover endpoints
+	endpoint      string
+	routes        []Route
 	label         string
 	database      string
 	host          string
@@ -36,25 +37,50 @@ type Writer struct {
 	BatchInterval time.Duration
 	BatchCount    int
 	Precision     string
+	// payloadSize limits encoded size of a batch, 0 means no limit
+	payloadSize  int
+	retry        retryPolicy
+	spool        *spool
+	done         chan struct{} // closed when shutdown starts
+	doneOnce     sync.Once
+	ctx          context.Context // canceled when shutdown deadline is passed
+	cancel       context.CancelFunc
+	flushChs     []chan chan error
+	overflow     string
+	blockTimeout time.Duration
+	mutex        sync.RWMutex // protects closed and messageCh from being closed under writers
+	closed       bool
+	stats        *writerStats
+	errorHandler ErrorHandler
+	cardinality  *cardinalityGuard // nil without limits
+	// retentionPolicy and writeConsistency are used for batches without routes
+	retentionPolicy  string
+	writeConsistency string
 }
 
 //NewWriter creates a new writer from config
 func NewWriter(cfg Config) (*Writer, error) {
-	c, err := client.NewHTTPClient(client.HTTPConfig{
-		Addr:     cfg.Endpoint,
-		Username: cfg.User,
-		Password: cfg.Password,
-	})
-	if err != nil {
+	if err := cfg.Validate(); err != nil {
 		return nil, err
 	}
-	//We should check precision, because it's the only reason to fail for newBatch
-	if _, err := time.ParseDuration("1" + cfg.Precision); err != nil {
-		log.Panicf("Can't parse Precision `%s`: %v", cfg.Precision, err)
+	cfg = cfg.withDefaults()
+	sink := cfg.Sink
+	if sink == nil {
+		c, err := newClient(cfg)
+		if err != nil {
+			return nil, err
+		}
+		sink = NewClientSink(c)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if cfg.APIVersion == 2 {
+		cfg.Database = cfg.Bucket // batches carry the bucket as database
 	}
 
 	w := &Writer{
-		client:        c,
+		sink:          sink,
+		endpoint:      cfg.Endpoint,
+		routes:        cfg.Routes,
 		database:      cfg.Database,
 		label:         cfg.Label,
 		host:          cfg.Host,
@@ -62,22 +88,78 @@ func NewWriter(cfg Config) (*Writer, error) {
 		BatchInterval: mustParseDuration(cfg.BatchInterval),
 		BatchCount:    cfg.BatchCount,
 		Precision:     cfg.Precision,
+		retry:         newRetryPolicy(cfg),
+		done:          make(chan struct{}),
+		ctx:           ctx,
+		cancel:        cancel,
+		overflow:      cfg.OverflowPolicy,
+		blockTimeout:  mustParseDuration(cfg.BlockTimeout),
+		stats:         newWriterStats(),
+		errorHandler:  cfg.ErrorHandler,
+
+		retentionPolicy:  cfg.RetentionPolicy,
+		writeConsistency: cfg.WriteConsistency,
+	}
+	if w.errorHandler == nil {
+		w.errorHandler = DefaultErrorHandler
 	}
-	if cfg.WorkerCount < 1 {
-		cfg.WorkerCount = 1
+	if c, ok := sink.(*clientSink); ok {
+		w.failover, _ = c.client.(*failoverClient)
+	}
+	if cfg.MaxTagValues > 0 || cfg.MaxSeriesPerMeasurement > 0 {
+		w.cardinality = newCardinalityGuard(cfg)
+	}
+	if cfg.Protocol == ProtocolUDP {
+		w.payloadSize = cfg.UDPPayloadSize
+	}
+	if cfg.SpoolDir != "" {
+		var maxAge time.Duration
+		if cfg.SpoolMaxAge != "" {
+			maxAge = mustParseDuration(cfg.SpoolMaxAge)
+		}
+		spool, err := newSpool(cfg.SpoolDir, cfg.SpoolMaxBytes, maxAge, w.errorHandler)
+		if err != nil {
+			cancel()
+			sink.Close()
+			return nil, err
+		}
+		w.spool = spool
+		w.wg.Add(1)
+		go w.replayer()
+	}
+	if cfg.SelfReport {
+		w.wg.Add(1)
+		go w.selfReporter(mustParseDuration(cfg.SelfReportInterval))
 	}
 	w.wg.Add(cfg.WorkerCount)
 	for i := 0; i < cfg.WorkerCount; i++ {
-		go w.worker()
+		flushCh := make(chan chan error)
+		w.flushChs = append(w.flushChs, flushCh)
+		go w.worker(flushCh)
 	}
 	return w, nil
 }
 
-//Close sends the rest of the messages and closes client
+func newClient(cfg Config) (client.Client, error) {
+	if len(cfg.FailoverEndpoints) > 0 {
+		return newFailoverClient(cfg)
+	}
+	switch cfg.Protocol {
+	case ProtocolHTTP:
+		return newHTTPClient(cfg)
+	case ProtocolUDP:
+		return client.NewUDPClient(client.UDPConfig{
+			Addr:        cfg.Endpoint,
+			PayloadSize: cfg.UDPPayloadSize,
+		})
+	default:
+		return nil, fmt.Errorf("unknown protocol `%s`", cfg.Protocol)
+	}
+}
+
+//Close sends the rest of the messages and closes sink
 func (s *Writer) Close() error {
-	close(s.messageCh)
-	s.wg.Wait() //let's send the rest
-	return s.client.Close()
+	return s.Shutdown(context.Background())
 }
 
 //WriteSample writes with given probability
@@ -89,99 +171,378 @@ func (s *Writer) WriteSample(p interface{}, prob float64) {
 
 //Write accepts metric and put it to the queue to write
 func (s *Writer) Write(p interface{}) {
+	_ = s.WriteContext(context.Background(), p)
+}
+
+// WriteContext puts the metric to the queue according to the overflow policy.
+// It returns nil if the metric was accepted, ErrQueueFull if it was discarded
+// and the context error if the context is done before there is room for it.
+func (s *Writer) WriteContext(ctx context.Context, p interface{}) error {
 	if p == nil {
-		return
+		return nil
 	}
-	if len(s.messageCh) >= cap(s.messageCh) {
-		log.Printf("[WARN] Discarded influx message, queue is full %d", len(s.messageCh))
-		return
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if s.closed {
+		return ErrWriterClosed
 	}
 
-	select {
-	case s.messageCh <- p:
+	switch s.overflow {
+	case OverflowBlock, OverflowBlockTimeout:
+		if s.overflow == OverflowBlockTimeout {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, s.blockTimeout)
+			defer cancel()
+		}
+		select {
+		case s.messageCh <- p:
+			s.stats.queued.Inc(1)
+			return nil
+		case <-s.done:
+			// shutdown waits for the lock held by blocked writers
+			return ErrWriterClosed
+		case <-ctx.Done():
+			s.stats.droppedFull.Inc(1)
+			s.errorHandler.HandleError(&DroppedMetricError{Metric: p, QueueLen: len(s.messageCh), Err: ctx.Err()})
+			return ctx.Err()
+		}
+	case OverflowDropOldest:
+		for {
+			select {
+			case s.messageCh <- p:
+				s.stats.queued.Inc(1)
+				return nil
+			default:
+			}
+			select {
+			case old := <-s.messageCh:
+				s.stats.droppedFull.Inc(1)
+				s.errorHandler.HandleError(&DroppedMetricError{Metric: old, QueueLen: len(s.messageCh), Oldest: true, Err: ErrQueueFull})
+			default:
+			}
+		}
 	default:
-		log.Printf("[ERROR] Discarded influx message, len didn't protect, queue is full %d", len(s.messageCh))
-
+		select {
+		case s.messageCh <- p:
+			s.stats.queued.Inc(1)
+			return nil
+		default:
+			s.stats.droppedFull.Inc(1)
+			s.errorHandler.HandleError(&DroppedMetricError{Metric: p, QueueLen: len(s.messageCh), Err: ErrQueueFull})
+			return ErrQueueFull
+		}
 	}
 }
 
-func (s *Writer) worker() {
+func (s *Writer) worker(flushCh chan chan error) {
 	defer s.wg.Done()
-	batch := newBatch(s.database, s.Precision)
+	batches := make(map[batchKey]client.BatchPoints)
 
 	tags := map[string]string{
 		"label": s.label,
 		"host":  s.host,
 	}
 
-	forceWriteChan := make(chan bool, forceChanLen)
-	go func() {
-		for {
-			time.Sleep(s.BatchInterval)
-			forceWriteChan <- true
-		}
-	}()
+	ticker := time.NewTicker(s.BatchInterval)
+	defer ticker.Stop()
 
 	count := 0
+	size := 0
+
+	write := func() error {
+		if count == 0 {
+			return nil
+		}
+		var ret error
+		for _, batch := range batches {
+			if err := s.send(batch); err != nil && ret == nil {
+				ret = err
+			}
+		}
+		atomic.AddInt64(&s.pending, -int64(count))
+		count = 0
+		size = 0
+		batches = make(map[batchKey]client.BatchPoints)
+		return ret
+	}
+
+	add := func(m interface{}) {
+		for _, p := range s.points(m, tags) {
+			if s.payloadSize > 0 {
+				// one batch is sent as a single datagram when possible,
+				// udp client always encodes timestamps in nanoseconds
+				pointSize := len(p.point.String()) + 1
+				if size+pointSize > s.payloadSize {
+					write()
+				}
+				size += pointSize
+			}
+			batch, ok := batches[p.key]
+			if !ok {
+				batch = s.newBatch(p.key)
+				batches[p.key] = batch
+			}
+			batch.AddPoint(p.point)
+			atomic.AddInt64(&s.pending, 1)
+			count++
+		}
+	}
 
 	for {
+		if s.ctx.Err() != nil {
+			return // shutdown deadline passed, the batch is abandoned
+		}
 		select {
 		case m, ok := <-s.messageCh:
 			if !ok {
-				if err := s.client.Write(batch); err != nil {
-					log.Printf("[ERROR] Can't write to influx %v", err)
-				}
+				write()
 				return
 			}
-			count += s.processMessage(m, batch, tags)
+			add(m)
 			if count > s.BatchCount {
+				write()
+			}
+		case ack := <-flushCh:
+			// take what is queued at the moment, other workers do the same
+		drain:
+			for n := len(s.messageCh); n > 0; n-- {
 				select {
-				case forceWriteChan <- true:
+				case m, ok := <-s.messageCh:
+					if !ok {
+						break drain
+					}
+					add(m)
 				default:
+					break drain
 				}
 			}
-		case <-forceWriteChan:
-			if count == 0 {
-				continue
+			ack <- write()
+		case <-ticker.C:
+			write()
+		case <-s.ctx.Done():
+		}
+	}
+}
+
+// Flush makes every worker write the queued metrics and its current batch,
+// it returns the first write error or the context error if the workers
+// don't finish in time.
+func (s *Writer) Flush(ctx context.Context) error {
+	acks := make(chan error, len(s.flushChs))
+	for _, ch := range s.flushChs {
+		select {
+		case ch <- acks:
+		case <-s.done:
+			return ErrWriterClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	var ret error
+	for range s.flushChs {
+		select {
+		case err := <-acks:
+			if err != nil && ret == nil {
+				ret = err
 			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return ret
+}
 
-			if err := s.client.Write(batch); err != nil {
-				log.Printf("[ERROR] Can't write to influx %v", err)
+// Shutdown stops accepting metrics and waits until the queued ones are
+// written. When the context is done first, retries are aborted and
+// ShutdownError reports the number of points which were not written. Workers
+// still in a write after shutdownGrace are not waited, the sink is closed
+// when they return.
+func (s *Writer) Shutdown(ctx context.Context) error {
+	first := false
+	s.doneOnce.Do(func() {
+		close(s.done) // releases writers blocked on the full queue
+		first = true
+	})
+	if !first {
+		return ErrWriterClosed
+	}
+	s.mutex.Lock()
+	s.closed = true
+	close(s.messageCh)
+	s.mutex.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		s.wg.Wait() //let's send the rest
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		s.cancel()
+		return s.sink.Close()
+	case <-ctx.Done():
+	}
+
+	s.cancel()
+	// workers leave at their next loop, the ones in a write are waited a
+	// little so they don't count the queue with us or use a closed sink
+	exited := true
+	timer := time.NewTimer(shutdownGrace)
+	select {
+	case <-finished:
+		timer.Stop()
+	case <-timer.C:
+		exited = false
+	}
+	abandoned := atomic.LoadInt64(&s.pending)
+	for m := range s.messageCh {
+		abandoned += int64(countPoints(m))
+	}
+	if exited {
+		s.sink.Close()
+	} else {
+		go func() {
+			<-finished
+			s.sink.Close()
+		}()
+	}
+	return &ShutdownError{Abandoned: abandoned, Err: ctx.Err()}
+}
+
+// send writes the batch retrying it according to the retry policy. Retries
+// block the worker, so the only retained batch is the current one and new
+// metrics wait in the bounded queue meanwhile. Shutdown deadline aborts the
+// retries, then the batch goes to the spool if there is one.
+func (s *Writer) send(batch client.BatchPoints) error {
+	for attempt := 1; ; attempt++ {
+		err := s.write(batch)
+		if err == nil {
+			return nil
+		}
+		writeErr := &WriteError{Points: len(batch.Points()), Attempts: attempt, Err: err}
+		if !isRetryable(err) {
+			s.stats.batchesFailed.Inc(1)
+			s.errorHandler.HandleError(writeErr)
+			return writeErr
+		}
+		if attempt < s.retry.maxAttempts {
+			writeErr.Retry = true
+			s.errorHandler.HandleError(writeErr)
+			timer := time.NewTimer(s.retry.backoff(attempt))
+			select {
+			case <-timer.C:
+				continue
+			case <-s.ctx.Done():
+				timer.Stop()
+				writeErr.Retry = false
+			}
+		}
+		if s.spool != nil {
+			if err := s.spool.append(batch); err == nil {
+				s.stats.pointsSpooled.Inc(int64(len(batch.Points())))
+				writeErr.Spooled = true
+				s.errorHandler.HandleError(writeErr)
+				return writeErr
 			}
-			count = 0
-			batch = newBatch(s.database, s.Precision) // Error is impossible here, because it's only if parsing is failed, but we already did it
 		}
+		s.stats.batchesFailed.Inc(1)
+		s.errorHandler.HandleError(writeErr)
+		return writeErr
 	}
 }
 
-func (s *Writer) processMessage(msg interface{}, batch client.BatchPoints, tags map[string]string) int {
-	ret := 0
+// replayer periodically sends spooled batches until the writer is closed
+func (s *Writer) replayer() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.BatchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.spool.replay(s.sink, s.write)
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// handleError reports the error to the writer's handler, collectors without
+// a writer use DefaultErrorHandler
+func (s *Writer) handleError(err error) {
+	if s == nil {
+		DefaultErrorHandler.HandleError(err)
+		return
+	}
+	s.errorHandler.HandleError(err)
+}
+
+// countPoints returns the number of metrics in the message
+func countPoints(msg interface{}) int {
+	if d, ok := msg.([]Metric); ok {
+		return len(d)
+	}
+	return 1
+}
+
+func (s *Writer) points(msg interface{}, tags map[string]string) []routedPoint {
+	var ret []routedPoint
+
+	add := func(m Metric) {
+		if s.cardinality != nil {
+			point, err := s.guardedPoint(tags, m)
+			if err != nil {
+				return
+			}
+			ret = append(ret, routedPoint{point: point, key: s.route(m)})
+			return
+		}
+		point, err := newPoint(tags, m)
+		if err != nil {
+			s.stats.droppedInvalid.Inc(1)
+			s.errorHandler.HandleError(&PointError{Metric: m, Err: err})
+			return
+		}
+		ret = append(ret, routedPoint{point: point, key: s.route(m)})
+	}
 
 	switch d := msg.(type) {
 	case *Metric:
-		newPoint(tags, batch, *d)
-		ret++
+		add(*d)
 	case Metric:
-		newPoint(tags, batch, d)
-		ret++
+		add(d)
 	case []Metric:
 		for _, m := range d {
-			newPoint(tags, batch, m)
-			ret++
+			add(m)
 		}
 	default:
-		log.Printf("[NEVER] Don't know how to cast metric, type: %T", msg)
+		s.stats.droppedInvalid.Inc(1)
+		s.errorHandler.HandleError(&PointError{Metric: msg, Err: fmt.Errorf("unsupported message type %T", msg)})
 	}
 	return ret
 }
 
-func newPoint(commonTags map[string]string, batch client.BatchPoints, m Metric) {
-	point, err := client.NewPoint(m.Measurement(), mergeTags(m.Tags(), commonTags), m.Values(), m.Time())
+// guardedPoint creates a point applying the cardinality limits to the metric tags
+func (s *Writer) guardedPoint(commonTags map[string]string, m Metric) (*client.Point, error) {
+	tags, values, violations, err := s.cardinality.check(m.Measurement(), m.Tags(), m.Values())
+	s.stats.cardinalityViolations.Inc(int64(violations))
 	if err != nil {
-		log.Printf("[ERROR] Can't create new point %v %v", m, err)
-		return
+		s.stats.droppedCardinality.Inc(1)
+		s.errorHandler.HandleError(err)
+		return nil, err
 	}
-	batch.AddPoint(point)
+	point, err := client.NewPoint(m.Measurement(), mergeTags(tags, commonTags), values, m.Time())
+	if err != nil {
+		s.stats.droppedInvalid.Inc(1)
+		s.errorHandler.HandleError(&PointError{Metric: m, Err: err})
+	}
+	return point, err
+}
+
+func newPoint(commonTags map[string]string, m Metric) (*client.Point, error) {
+	return client.NewPoint(m.Measurement(), mergeTags(m.Tags(), commonTags), m.Values(), m.Time())
 }
 
 func newBatch(database, precision string) client.BatchPoints {
@@ -191,12 +552,18 @@ func newBatch(database, precision string) client.BatchPoints {
 	})
 	return c
 }
+
+// mergeTags returns a new map, metrics can be shared by several writers
 func mergeTags(tags, commonTags map[string]string) map[string]string {
 	if tags == nil {
 		return commonTags
 	}
+	ret := make(map[string]string, len(tags)+len(commonTags))
+	for k, v := range tags {
+		ret[k] = v
+	}
 	for k, v := range commonTags {
-		tags[k] = v
+		ret[k] = v
 	}
-	return tags
+	return ret
 }
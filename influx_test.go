@@ -1,8 +1,19 @@
 package influx
 
 import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/influxdata/influxdb/client/v2"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -11,18 +22,310 @@ func TestConfig(t *testing.T) {
 		Endpoint:  "http://127.0.0.1:8086",
 		Precision: "incorrect",
 	}
-	assert.Panics(t, func() {
-		_, e := NewWriter(cfg)
-		assert.NoError(t, e)
-	})
+	_, e := NewWriter(cfg)
+	assert.Error(t, e)
 
 	cfg.Precision = "ms"
-	assert.Panics(t, func() {
-		_, e := NewWriter(cfg)
-		assert.NoError(t, e)
+	cfg.BatchInterval = "1s"
+	w, e := NewWriter(cfg)
+	if assert.NoError(t, e) {
+		assert.NoError(t, w.Close())
+	}
+
+	// omitted fields get defaults
+	w, e = NewWriter(Config{Endpoint: "http://127.0.0.1:8086"})
+	if assert.NoError(t, e) {
+		assert.Equal(t, time.Second, w.BatchInterval)
+		assert.Equal(t, DefaultBatchCount, w.BatchCount)
+		assert.Equal(t, DefaultPrecision, w.Precision)
+		assert.NoError(t, w.Close())
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	assert.NoError(t, Config{Endpoint: "https://influx:8086"}.Validate())
+	assert.NoError(t, Config{Endpoint: "influx:8089", Protocol: ProtocolUDP}.Validate())
+
+	err := Config{
+		Endpoint:       "influx:8086",
+		Precision:      "us",
+		BatchInterval:  "soon",
+		BatchCount:     -1,
+		RetryJitter:    2,
+		OverflowPolicy: "wait",
+	}.Validate()
+	if assert.IsType(t, ConfigError{}, err) {
+		assert.Len(t, err.(ConfigError), 6, err.Error())
+	}
+}
+
+func TestUDPWriter(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+
+	w, err := NewWriter(Config{
+		Endpoint:       conn.LocalAddr().String(),
+		Protocol:       ProtocolUDP,
+		UDPPayloadSize: 128,
+		BatchInterval:  "1h",
+		BatchCount:     1000,
+		Precision:      "s",
 	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	for i := 0; i < 10; i++ {
+		w.Write(SimpleMetric{
+			Name:       "udp_test",
+			ValuesMap:  map[string]interface{}{"value": i},
+			CreateTime: time.Unix(1500000000, 0),
+		})
+	}
+	assert.NoError(t, w.Close())
 
-	cfg.BatchInterval = "1s"
-	_, e := NewWriter(cfg)
-	assert.NoError(t, e)
+	lines := 0
+	buf := make([]byte, 65536)
+	for lines < 10 {
+		assert.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+		n, err := conn.Read(buf)
+		if !assert.NoError(t, err) {
+			return
+		}
+		assert.True(t, n <= 128, "datagram of %d bytes exceeds payload size", n)
+		for _, line := range strings.Split(strings.TrimSpace(string(buf[:n])), "\n") {
+			assert.True(t, strings.HasPrefix(line, "udp_test value="), line)
+			lines++
+		}
+	}
+	assert.Equal(t, 10, lines)
+}
+
+func TestOverflowPolicy(t *testing.T) {
+	for policy, expected := range map[string]error{
+		OverflowDropNewest:   ErrQueueFull,
+		OverflowDropOldest:   nil,
+		OverflowBlock:        context.Canceled,
+		OverflowBlockTimeout: context.DeadlineExceeded,
+	} {
+		entered := make(chan struct{}, 1)
+		release := make(chan struct{})
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case entered <- struct{}{}:
+			default:
+			}
+			<-release
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+		w, err := NewWriter(Config{
+			Endpoint:       srv.URL,
+			BatchInterval:  "1ms",
+			Precision:      "s",
+			OverflowPolicy: policy,
+			BlockTimeout:   "10ms",
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+		m := SimpleMetric{Name: "overflow", ValuesMap: map[string]interface{}{"value": 1}, CreateTime: time.Now()}
+		// the only worker gets stuck writing the first metric
+		assert.NoError(t, w.WriteContext(context.Background(), m))
+		<-entered
+		for i := 0; i < cap(w.messageCh); i++ {
+			assert.NoError(t, w.WriteContext(context.Background(), m))
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(50*time.Millisecond, cancel) // after BlockTimeout
+		assert.Equal(t, expected, w.WriteContext(ctx, m), policy)
+
+		if policy == OverflowBlock {
+			// a blocked write returns when the writer is shut down
+			blocked := make(chan error, 1)
+			go func() { blocked <- w.WriteContext(context.Background(), m) }()
+			time.Sleep(10 * time.Millisecond)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			assert.IsType(t, &ShutdownError{}, w.Shutdown(ctx))
+			cancel()
+			assert.Equal(t, ErrWriterClosed, <-blocked)
+			close(release)
+		} else {
+			close(release)
+			assert.NoError(t, w.Close())
+		}
+		assert.Equal(t, ErrWriterClosed, w.WriteContext(context.Background(), m))
+		srv.Close()
+	}
+}
+
+func TestStats(t *testing.T) {
+	var mutex sync.Mutex
+	var body bytes.Buffer
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		body.ReadFrom(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	w, err := NewWriter(Config{
+		Endpoint:           srv.URL,
+		BatchInterval:      "1h",
+		Precision:          "s",
+		SelfReport:         true,
+		SelfReportInterval: "10ms",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	w.Write([]Metric{
+		SimpleMetric{Name: "stats", ValuesMap: map[string]interface{}{"value": 1}},
+		SimpleMetric{Name: "stats", ValuesMap: map[string]interface{}{"value": 2}},
+	})
+	w.Write(SimpleMetric{Name: "stats"}) // a point without fields is invalid
+	time.Sleep(50 * time.Millisecond)
+	assert.NoError(t, w.Close())
+
+	stats := w.Stats()
+	assert.True(t, stats.Queued > 2)
+	assert.EqualValues(t, 1, stats.DroppedInvalid)
+	assert.Equal(t, stats.Queued, stats.PointsWritten) // the slice adds a point, the invalid metric takes one
+	assert.EqualValues(t, 0, stats.BatchesFailed)
+	assert.EqualValues(t, 0, stats.QueueDepth)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Contains(t, body.String(), "go_influx_writer ")
+	assert.Contains(t, body.String(), "dropped_invalid=1i")
+}
+
+func TestFlush(t *testing.T) {
+	var lines int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		atomic.AddInt32(&lines, int32(strings.Count(string(body), "\n")))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	w, err := NewWriter(Config{Endpoint: srv.URL, BatchInterval: "1h", WorkerCount: 2})
+	if !assert.NoError(t, err) {
+		return
+	}
+	for i := 0; i < 3; i++ {
+		w.Write(SimpleMetric{Name: "flush", ValuesMap: map[string]interface{}{"value": i}})
+	}
+	assert.NoError(t, w.Flush(context.Background()))
+	assert.EqualValues(t, 3, atomic.LoadInt32(&lines))
+	assert.NoError(t, w.Close())
+	assert.Equal(t, ErrWriterClosed, w.Flush(context.Background()))
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	w, err := NewWriter(Config{Endpoint: srv.URL, BatchInterval: "1h", BatchCount: 1})
+	if !assert.NoError(t, err) {
+		return
+	}
+	for i := 0; i < 5; i++ {
+		w.Write(SimpleMetric{Name: "shutdown", ValuesMap: map[string]interface{}{"value": i}})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = w.Shutdown(ctx)
+	if assert.IsType(t, &ShutdownError{}, err) {
+		assert.EqualValues(t, 5, err.(*ShutdownError).Abandoned)
+		assert.Equal(t, context.DeadlineExceeded, err.(*ShutdownError).Err)
+	}
+}
+
+func TestShutdownBlockedWriter(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	w, err := NewWriter(Config{Endpoint: srv.URL, BatchCount: 1, OverflowPolicy: OverflowBlock})
+	if !assert.NoError(t, err) {
+		return
+	}
+	stopped := make(chan error, 1)
+	go func() {
+		// blocks on the full queue while the worker is stuck in a write
+		for {
+			if err := w.WriteContext(context.Background(), SimpleMetric{Name: "blocked", ValuesMap: map[string]interface{}{"value": 1}}); err != nil {
+				stopped <- err
+				return
+			}
+		}
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = w.Shutdown(ctx)
+	assert.IsType(t, &ShutdownError{}, err)
+	assert.True(t, time.Since(start) < time.Second, "shutdown took %v", time.Since(start))
+	select {
+	case err := <-stopped:
+		assert.Equal(t, ErrWriterClosed, err)
+	case <-time.After(time.Second):
+		t.Error("writer is still blocked")
+	}
+}
+
+// blockingSink ignores the context of writes like the influx client
+type blockingSink struct {
+	release chan struct{}
+	closed  chan struct{}
+}
+
+func (s *blockingSink) WriteBatch(ctx context.Context, bp client.BatchPoints) error {
+	<-s.release
+	return nil
+}
+
+func (s *blockingSink) Close() error {
+	close(s.closed)
+	return nil
+}
+
+func TestShutdownInFlightWrite(t *testing.T) {
+	sink := &blockingSink{release: make(chan struct{}), closed: make(chan struct{})}
+	w, err := NewWriter(Config{Sink: sink, BatchInterval: "1h", BatchCount: 1})
+	if !assert.NoError(t, err) {
+		return
+	}
+	for i := 0; i < 3; i++ {
+		w.Write(SimpleMetric{Name: "shutdown", ValuesMap: map[string]interface{}{"value": i}})
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.IsType(t, &ShutdownError{}, w.Shutdown(ctx))
+	select {
+	case <-sink.closed:
+		t.Error("sink closed under a write")
+	default:
+	}
+	close(sink.release)
+	select {
+	case <-sink.closed:
+	case <-time.After(time.Second):
+		t.Error("sink not closed after the write")
+	}
 }
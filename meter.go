@@ -0,0 +1,163 @@
+package influx
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const meterTickInterval = 5 * time.Second
+
+// Meter counts events to produce exponentially-weighted moving average rates
+// at one-, five-, and fifteen-minutes and a mean rate.
+type Meter interface {
+	Count() int64
+	Mark(int64)
+	Rate1() float64
+	Rate5() float64
+	Rate15() float64
+	RateMean() float64
+	Stop()
+}
+
+// StandardMeter is the standard implementation of a Meter. All meters are
+// ticked by one shared background ticker, which runs while there are meters
+// which are not stopped.
+type StandardMeter struct {
+	count     int64 // first for atomic alignment
+	stopAfter int64 // nanoseconds from start to Stop, 0 while running
+	a1        EWMA
+	a5        EWMA
+	a15       EWMA
+	startTime time.Time
+	stopped   uint32
+}
+
+// NewMeter constructs a new StandardMeter and registers it in the ticker.
+func NewMeter() Meter {
+	m := &StandardMeter{
+		a1:        NewEWMA1(),
+		a5:        NewEWMA5(),
+		a15:       NewEWMA15(),
+		startTime: time.Now(),
+	}
+	arbiter.add(m)
+	return m
+}
+
+// Count returns the number of events recorded.
+func (m *StandardMeter) Count() int64 {
+	return atomic.LoadInt64(&m.count)
+}
+
+// Mark records the occurrence of n events.
+func (m *StandardMeter) Mark(n int64) {
+	if atomic.LoadUint32(&m.stopped) == 1 {
+		return
+	}
+	atomic.AddInt64(&m.count, n)
+	m.a1.Update(n)
+	m.a5.Update(n)
+	m.a15.Update(n)
+}
+
+// Rate1 returns the one-minute moving average rate of events per second.
+func (m *StandardMeter) Rate1() float64 {
+	return m.a1.Rate()
+}
+
+// Rate5 returns the five-minute moving average rate of events per second.
+func (m *StandardMeter) Rate5() float64 {
+	return m.a5.Rate()
+}
+
+// Rate15 returns the fifteen-minute moving average rate of events per second.
+func (m *StandardMeter) Rate15() float64 {
+	return m.a15.Rate()
+}
+
+// RateMean returns the meter's mean rate of events per second.
+func (m *StandardMeter) RateMean() float64 {
+	elapsed := time.Since(m.startTime).Seconds()
+	if d := atomic.LoadInt64(&m.stopAfter); d > 0 {
+		elapsed = time.Duration(d).Seconds()
+	}
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(m.Count()) / elapsed
+}
+
+// Stop stops the meter, Mark is a no-op and rates are frozen after it.
+func (m *StandardMeter) Stop() {
+	if atomic.CompareAndSwapUint32(&m.stopped, 0, 1) {
+		atomic.StoreInt64(&m.stopAfter, int64(time.Since(m.startTime)))
+		arbiter.remove(m)
+	}
+}
+
+func (m *StandardMeter) tick() {
+	m.a1.Tick()
+	m.a5.Tick()
+	m.a15.Tick()
+}
+
+// meterArbiter ticks the meters every 5 seconds
+type meterArbiter struct {
+	mutex  sync.Mutex
+	meters map[*StandardMeter]struct{}
+	ticker *time.Ticker
+	stop   chan struct{}
+}
+
+var arbiter = meterArbiter{meters: make(map[*StandardMeter]struct{})}
+
+func (a *meterArbiter) add(m *StandardMeter) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.meters[m] = struct{}{}
+	if a.ticker == nil {
+		a.ticker = time.NewTicker(meterTickInterval)
+		a.stop = make(chan struct{})
+		go a.run(a.ticker, a.stop)
+	}
+}
+
+func (a *meterArbiter) remove(m *StandardMeter) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	delete(a.meters, m)
+	if len(a.meters) == 0 && a.ticker != nil {
+		a.ticker.Stop()
+		close(a.stop)
+		a.ticker = nil
+	}
+}
+
+func (a *meterArbiter) run(ticker *time.Ticker, stop chan struct{}) {
+	for {
+		select {
+		case <-ticker.C:
+			a.tick()
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (a *meterArbiter) tick() {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for m := range a.meters {
+		m.tick()
+	}
+}
+
+// meterValues flattens the meter into name.count, name.m1, name.m5, name.m15 and name.mean_rate fields
+func meterValues(values map[string]interface{}, name string, m Meter) {
+	values[fieldName(name, "count")] = m.Count()
+	values[fieldName(name, "m1")] = m.Rate1()
+	values[fieldName(name, "m5")] = m.Rate5()
+	values[fieldName(name, "m15")] = m.Rate15()
+	values[fieldName(name, "mean_rate")] = m.RateMean()
+}
@@ -0,0 +1,46 @@
+package influx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEWMA(t *testing.T) {
+	a := NewEWMA1()
+	a.Update(3)
+	a.Tick()
+	assert.InDelta(t, 0.6, a.Rate(), 1e-9)
+	for i := 0; i < 12; i++ { // one minute without events
+		a.Tick()
+	}
+	assert.InDelta(t, 0.22072766, a.Rate(), 1e-8)
+}
+
+func TestMeter(t *testing.T) {
+	m := NewMeter().(*StandardMeter)
+	m.Mark(10)
+	m.Mark(5)
+	m.tick()
+	assert.EqualValues(t, 15, m.Count())
+	assert.InDelta(t, 3.0, m.Rate1(), 1e-9)
+	assert.InDelta(t, 3.0, m.Rate15(), 1e-9)
+	assert.True(t, m.RateMean() > 0)
+
+	r := NewRegistry()
+	assert.NoError(t, r.Register("shares", m))
+	values := newRegistryMetric("test", r).Values()
+	assert.Equal(t, int64(15), values["shares.count"])
+	assert.InDelta(t, 3.0, values["shares.m1"], 1e-9)
+
+	r.Unregister("shares") // stops the meter
+	m.Mark(1)
+	assert.EqualValues(t, 15, m.Count())
+	rate := m.RateMean()
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, rate, m.RateMean())
+	arbiter.mutex.Lock()
+	defer arbiter.mutex.Unlock()
+	assert.NotContains(t, arbiter.meters, m)
+}
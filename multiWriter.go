@@ -0,0 +1,121 @@
+package influx
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"path/filepath"
+	"sync"
+)
+
+// MultiWriter replicates metrics to several endpoints. Every endpoint is
+// written by its own Writer with a separate queue, workers, retries and
+// spool, so a slow or unreachable endpoint doesn't hold up the others as
+// long as its overflow policy doesn't block.
+type MultiWriter struct {
+	writers []*Writer
+}
+
+// NewMultiWriter creates a writer for every config, the configs are
+// validated and get defaults the same way as by NewWriter. Every endpoint
+// needs its own SpoolDir, otherwise it would replay batches of the others.
+func NewMultiWriter(cfgs ...Config) (*MultiWriter, error) {
+	spoolDirs := make(map[string]int)
+	for i, cfg := range cfgs {
+		if cfg.SpoolDir == "" {
+			continue
+		}
+		dir := filepath.Clean(cfg.SpoolDir)
+		if j, ok := spoolDirs[dir]; ok {
+			return nil, ConfigError{fmt.Sprintf("spool_dir `%s` is shared by configs %d and %d", cfg.SpoolDir, j, i)}
+		}
+		spoolDirs[dir] = i
+	}
+
+	mw := &MultiWriter{}
+	for _, cfg := range cfgs {
+		w, err := NewWriter(cfg)
+		if err != nil {
+			mw.Close()
+			return nil, err
+		}
+		mw.writers = append(mw.writers, w)
+	}
+	return mw, nil
+}
+
+// Writers returns the writers of the endpoints in order of the configs.
+func (mw *MultiWriter) Writers() []*Writer {
+	return mw.writers
+}
+
+// Write puts the metric to the queues of all endpoints.
+func (mw *MultiWriter) Write(p interface{}) {
+	for _, w := range mw.writers {
+		w.Write(p)
+	}
+}
+
+// WriteContext puts the metric to the queues of all endpoints according to
+// their overflow policies. It returns the first error, the metric is still
+// given to the rest of the endpoints.
+func (mw *MultiWriter) WriteContext(ctx context.Context, p interface{}) error {
+	var ret error
+	for _, w := range mw.writers {
+		if err := w.WriteContext(ctx, p); err != nil && ret == nil {
+			ret = err
+		}
+	}
+	return ret
+}
+
+// WriteSample writes with given probability, a sampled metric goes to all endpoints.
+func (mw *MultiWriter) WriteSample(p interface{}, prob float64) {
+	if rand.Float64() < prob {
+		mw.Write(p)
+	}
+}
+
+// Flush flushes all endpoints in parallel and returns the first error.
+func (mw *MultiWriter) Flush(ctx context.Context) error {
+	return mw.each(func(w *Writer) error { return w.Flush(ctx) })
+}
+
+// Shutdown shuts down all endpoints in parallel and returns the first error.
+func (mw *MultiWriter) Shutdown(ctx context.Context) error {
+	return mw.each(func(w *Writer) error { return w.Shutdown(ctx) })
+}
+
+// Close sends the rest of the messages to all endpoints and closes them.
+func (mw *MultiWriter) Close() error {
+	return mw.Shutdown(context.Background())
+}
+
+// Stats returns the stats of the endpoints in order of the configs.
+func (mw *MultiWriter) Stats() []WriterStats {
+	stats := make([]WriterStats, len(mw.writers))
+	for i, w := range mw.writers {
+		stats[i] = w.Stats()
+	}
+	return stats
+}
+
+// each calls f for every writer in parallel and returns the first error in order of the writers
+func (mw *MultiWriter) each(f func(w *Writer) error) error {
+	errs := make([]error, len(mw.writers))
+	var wg sync.WaitGroup
+	wg.Add(len(mw.writers))
+	for i, w := range mw.writers {
+		go func(i int, w *Writer) {
+			defer wg.Done()
+			errs[i] = f(w)
+		}(i, w)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
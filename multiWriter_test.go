@@ -0,0 +1,64 @@
+package influx
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMultiWriter(t *testing.T) {
+	var primaryLines int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "metrics", r.URL.Query().Get("db"))
+		body, _ := ioutil.ReadAll(r.Body)
+		atomic.AddInt32(&primaryLines, int32(strings.Count(string(body), "\n")))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer primary.Close()
+	release := make(chan struct{})
+	replica := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "analytics", r.URL.Query().Get("db"))
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer replica.Close()
+
+	_, err := NewMultiWriter(Config{Endpoint: primary.URL}, Config{Endpoint: "replica"})
+	assert.Error(t, err)
+	_, err = NewMultiWriter(
+		Config{Endpoint: primary.URL, SpoolDir: "spool"},
+		Config{Endpoint: replica.URL, SpoolDir: "./spool/"},
+	)
+	assert.IsType(t, ConfigError{}, err)
+
+	mw, err := NewMultiWriter(
+		Config{Endpoint: primary.URL, Database: "metrics", BatchInterval: "1h"},
+		Config{Endpoint: replica.URL, Database: "analytics", BatchInterval: "1h", BatchCount: 1},
+	)
+	if !assert.NoError(t, err) {
+		return
+	}
+	tags := map[string]string{"coin": "btc"}
+	for i := 0; i < 3; i++ {
+		mw.Write(SimpleMetric{Name: "shares", TagsMap: tags, ValuesMap: map[string]interface{}{"value": i}})
+	}
+	// the replica is stuck, the primary isn't held up by it
+	assert.NoError(t, mw.Writers()[0].Flush(context.Background()))
+	assert.EqualValues(t, 3, atomic.LoadInt32(&primaryLines))
+	assert.Equal(t, map[string]string{"coin": "btc"}, tags)
+
+	close(release)
+	assert.NoError(t, mw.Close())
+	stats := mw.Stats()
+	if assert.Len(t, stats, 2) {
+		assert.EqualValues(t, 3, stats[0].PointsWritten)
+		assert.EqualValues(t, 3, stats[1].PointsWritten)
+	}
+	assert.Equal(t, ErrWriterClosed, mw.Flush(context.Background()))
+}
@@ -0,0 +1,220 @@
+package influx
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	processMeasurement = "process"
+	// clockTicks is USER_HZ of /proc/[pid]/stat times, it is 100 on all
+	// supported architectures
+	clockTicks = 100
+)
+
+// ProcessCollectorOptions configures the points written by a ProcessCollector.
+type ProcessCollectorOptions struct {
+	// Measurement is the measurement name, "process" by default
+	Measurement string
+	// Tags are added to every point
+	Tags map[string]string
+	// ProcRoot is the mount point of procfs, "/proc" by default
+	ProcRoot string
+	// PID is the process to report, the current process by default
+	PID int
+}
+
+// ProcessCollector periodically writes CPU time, memory, open file
+// descriptors, threads and context switches of a process read from /proc,
+// so it works only on Linux.
+type ProcessCollector struct {
+	collector
+	writer *Writer
+	opts   ProcessCollectorOptions
+	dir    string
+	// previous CPU time for the usage since the last collect
+	cpuMutex sync.Mutex
+	cpu      float64
+	cpuTime  time.Time
+}
+
+// NewProcessCollector starts goroutine which writes stats of the current process to influx writer every duration
+func NewProcessCollector(influx *Writer, d time.Duration) *ProcessCollector {
+	c := NewCustomProcessCollector(influx, d, ProcessCollectorOptions{})
+	c.Start()
+	return c
+}
+
+// NewCustomProcessCollector creates a collector with the options, it writes
+// nothing until Start is called.
+func NewCustomProcessCollector(influx *Writer, d time.Duration, opts ProcessCollectorOptions) *ProcessCollector {
+	if opts.Measurement == "" {
+		opts.Measurement = processMeasurement
+	}
+	if opts.ProcRoot == "" {
+		opts.ProcRoot = "/proc"
+	}
+	pid := "self"
+	if opts.PID > 0 {
+		pid = strconv.Itoa(opts.PID)
+	}
+	c := &ProcessCollector{
+		writer: influx,
+		opts:   opts,
+		dir:    filepath.Join(opts.ProcRoot, pid),
+	}
+	c.interval, c.collect = d, c.Collect
+	return c
+}
+
+// Collect reads the process stats and writes them now. Files which can't be
+// read are reported to the error handler of the writer, the rest of the
+// stats is written.
+func (c *ProcessCollector) Collect() {
+	values, err := readProcess(c.dir)
+	if err != nil {
+		c.writer.handleError(err)
+	}
+	if len(values) == 0 {
+		return
+	}
+
+	tm := time.Now()
+	c.cpuMutex.Lock()
+	if cpu, ok := values["cpu_seconds"].(float64); ok {
+		if !c.cpuTime.IsZero() {
+			if d := tm.Sub(c.cpuTime).Seconds(); d > 0 {
+				values["cpu_usage"] = (cpu - c.cpu) / d
+			}
+		}
+		c.cpu, c.cpuTime = cpu, tm
+	}
+	c.cpuMutex.Unlock()
+
+	c.writer.Write(runtimeMetric{
+		measurement: c.opts.Measurement,
+		tags:        copyTags(c.opts.Tags),
+		values:      values,
+		time:        tm.UTC(),
+	})
+}
+
+// readProcess reads stat, status, fd and limits of the process directory,
+// it returns the values read and the first error
+func readProcess(dir string) (map[string]interface{}, error) {
+	values := make(map[string]interface{})
+	var ret error
+	for _, read := range []func(string, map[string]interface{}) error{readProcStat, readProcStatus, readProcFD, readProcLimits} {
+		if err := read(dir, values); err != nil && ret == nil {
+			ret = err
+		}
+	}
+	return values, ret
+}
+
+// readProcStat reads CPU time, virtual memory size and threads from stat
+func readProcStat(dir string, values map[string]interface{}) error {
+	data, err := ioutil.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return err
+	}
+	// the command name may contain spaces and parentheses
+	i := strings.LastIndexByte(string(data), ')')
+	if i < 0 {
+		return fmt.Errorf("influx: unexpected format of %s", filepath.Join(dir, "stat"))
+	}
+	// fields start from the 3rd one, state
+	fields := strings.Fields(string(data[i+1:]))
+	if len(fields) < 21 {
+		return fmt.Errorf("influx: unexpected format of %s", filepath.Join(dir, "stat"))
+	}
+	var stat [4]int64
+	for j, field := range []int{11, 12, 17, 20} { // utime, stime, num_threads, vsize
+		if stat[j], err = strconv.ParseInt(fields[field], 10, 64); err != nil {
+			return fmt.Errorf("influx: %s: %v", filepath.Join(dir, "stat"), err)
+		}
+	}
+	values["cpu_user_seconds"] = float64(stat[0]) / clockTicks
+	values["cpu_system_seconds"] = float64(stat[1]) / clockTicks
+	values["cpu_seconds"] = float64(stat[0]+stat[1]) / clockTicks
+	values["threads"] = stat[2]
+	values["virtual_bytes"] = stat[3]
+	return nil
+}
+
+// readProcStatus reads resident memory and context switches from status
+func readProcStatus(dir string, values map[string]interface{}) error {
+	f, err := os.Open(filepath.Join(dir, "status"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fields := map[string]struct {
+		name  string
+		scale int64
+	}{
+		"VmRSS":                      {"resident_bytes", 1024},
+		"VmHWM":                      {"resident_peak_bytes", 1024},
+		"voluntary_ctxt_switches":    {"voluntary_ctxt_switches", 1},
+		"nonvoluntary_ctxt_switches": {"nonvoluntary_ctxt_switches", 1},
+	}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		field, ok := fields[parts[0]]
+		if !ok || len(parts) < 2 {
+			continue
+		}
+		value := strings.Fields(parts[1]) // "1836 kB"
+		if len(value) == 0 {
+			continue
+		}
+		v, err := strconv.ParseInt(value[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("influx: %s: %v", filepath.Join(dir, "status"), err)
+		}
+		values[field.name] = v * field.scale
+	}
+	return scanner.Err()
+}
+
+// readProcFD counts open file descriptors
+func readProcFD(dir string, values map[string]interface{}) error {
+	fds, err := ioutil.ReadDir(filepath.Join(dir, "fd"))
+	if err != nil {
+		return err
+	}
+	values["open_fds"] = int64(len(fds))
+	return nil
+}
+
+// readProcLimits reads the soft limit of open files from limits
+func readProcLimits(dir string, values map[string]interface{}) error {
+	data, err := ioutil.ReadFile(filepath.Join(dir, "limits"))
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "Max open files") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(line, "Max open files"))
+		if len(fields) == 0 || fields[0] == "unlimited" {
+			return nil
+		}
+		v, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("influx: %s: %v", filepath.Join(dir, "limits"), err)
+		}
+		values["max_fds"] = v
+	}
+	return nil
+}
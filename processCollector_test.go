@@ -0,0 +1,63 @@
+package influx
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadProcess(t *testing.T) {
+	values, err := readProcess("testdata/proc/1234")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"cpu_user_seconds":           15.2,
+		"cpu_system_seconds":         3.8,
+		"cpu_seconds":                19.0,
+		"threads":                    int64(12),
+		"virtual_bytes":              int64(1024000000),
+		"resident_bytes":             int64(120000 * 1024),
+		"resident_peak_bytes":        int64(150000 * 1024),
+		"voluntary_ctxt_switches":    int64(5210),
+		"nonvoluntary_ctxt_switches": int64(73),
+		"open_fds":                   int64(5),
+		"max_fds":                    int64(65536),
+	}, values)
+
+	// the rest is read when a file is missing
+	values, err = readProcess("testdata/proc/none")
+	assert.Error(t, err)
+	assert.Empty(t, values)
+}
+
+func TestProcessCollector(t *testing.T) {
+	lines := make(chan string, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		lines <- strings.TrimSpace(string(body))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	var handled []error
+	w, err := NewWriter(Config{Endpoint: srv.URL, BatchInterval: "1h", ErrorHandler: ErrorHandlerFunc(func(err error) { handled = append(handled, err) })})
+	if !assert.NoError(t, err) {
+		return
+	}
+	c := NewCustomProcessCollector(w, time.Hour, ProcessCollectorOptions{ProcRoot: "testdata/proc", PID: 1234, Tags: map[string]string{"app": "pool"}})
+	c.Collect()
+	c.Collect()
+	// read errors go to the handler of the writer
+	NewCustomProcessCollector(w, time.Hour, ProcessCollectorOptions{ProcRoot: "testdata/proc", PID: 1}).Collect()
+	assert.NoError(t, w.Close())
+	assert.Len(t, handled, 1)
+
+	line := <-lines
+	assert.True(t, strings.HasPrefix(line, "process,app=pool "), line)
+	assert.Contains(t, line, "open_fds=5i")
+	assert.Contains(t, line, "cpu_usage=0") // the fixture doesn't change
+}
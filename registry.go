@@ -2,7 +2,6 @@ package influx
 
 import (
 	"fmt"
-	"log"
 	"reflect"
 	"sync"
 )
@@ -38,6 +37,11 @@ type Registry interface {
 	Unregister(string)
 }
 
+// stoppable metrics use background resources which are released by Stop
+type stoppable interface {
+	Stop()
+}
+
 // The standard implementation of a Registry is a mutex-protected map
 // of names to metrics.
 type standardRegistry struct {
@@ -78,8 +82,7 @@ func (r *standardRegistry) GetOrRegister(name string, i interface{}) interface{}
 		i = v.Call(nil)[0].Interface()
 	}
 	if err := r.register(name, i); err != nil {
-		log.Printf("[ERROR] Can't register metric name %s: %v", name, err)
-
+		DefaultErrorHandler.HandleError(err)
 	}
 	return i
 }
@@ -92,10 +95,13 @@ func (r *standardRegistry) Register(name string, i interface{}) error {
 	return r.register(name, i)
 }
 
-// Unregister the metric with the given name.
+// Unregister the metric with the given name. Meters and timers are stopped.
 func (r *standardRegistry) Unregister(name string) {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
+	if m, ok := r.metrics[name].(stoppable); ok {
+		m.Stop()
+	}
 	delete(r.metrics, name)
 }
 
@@ -104,10 +110,10 @@ func (r *standardRegistry) register(name string, i interface{}) error {
 		return DuplicateMetric(name)
 	}
 	switch i.(type) {
-	case Gauge, Counter:
+	case Gauge, GaugeFloat64, Counter, Histogram, Meter, Timer, vector:
 		r.metrics[name] = i
 	default:
-		log.Printf("[Never] Unkwnown type to register: %T", i)
+		DefaultErrorHandler.HandleError(fmt.Errorf("influx: unsupported metric type %T of %s", i, name))
 	}
 	return nil
 }
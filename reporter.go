@@ -0,0 +1,202 @@
+package influx
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Supported values of ReporterOptions.Mode
+const (
+	// ReportSingle writes all metrics as fields of one measurement named
+	// ReporterOptions.Measurement, as the runtime collector does.
+	ReportSingle = "single"
+	// ReportPerMetric writes a measurement per metric named after it with
+	// bare fields like value, count or p99.
+	ReportPerMetric = "per-metric"
+	// ReportByPrefix groups metrics by the name up to the last separator,
+	// "pool.shares.accepted" becomes field "accepted" of "pool.shares".
+	// A name without separator is reported as by ReportPerMetric unless
+	// ReporterOptions.Measurement is set.
+	ReportByPrefix = "prefix"
+)
+
+// reportMeasurement is the measurement of ReportSingle when none is given
+const reportMeasurement = "metrics"
+
+// ReporterOptions configures how a Reporter maps metric names to measurements and fields.
+type ReporterOptions struct {
+	// Mode is one of Report* values, ReportPerMetric by default
+	Mode string
+	// Measurement is the measurement name for ReportSingle, "metrics" by
+	// default, and the prefix of measurement names for the other modes
+	Measurement string
+	// Separator splits metric names for ReportByPrefix, "." by default
+	Separator string
+	// Tags are added to every reported point
+	Tags map[string]string
+}
+
+// Reporter periodically writes snapshots of a Registry to a Writer.
+type Reporter struct {
+	registry Registry
+	writer   *Writer
+	opts     ReporterOptions
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewReporter starts reporting the registry to the writer every interval
+// until Stop is called, DefaultCollectInterval is used if the interval isn't
+// positive.
+func NewReporter(r Registry, w *Writer, interval time.Duration, opts ReporterOptions) *Reporter {
+	if opts.Mode == "" {
+		opts.Mode = ReportPerMetric
+	}
+	if opts.Separator == "" {
+		opts.Separator = "."
+	}
+	if opts.Mode == ReportSingle && opts.Measurement == "" {
+		opts.Measurement = reportMeasurement
+	}
+	rp := &Reporter{
+		registry: r,
+		writer:   w,
+		opts:     opts,
+		stop:     make(chan struct{}),
+	}
+	rp.wg.Add(1)
+	go rp.run(interval)
+	return rp
+}
+
+func (rp *Reporter) run(interval time.Duration) {
+	defer rp.wg.Done()
+	ticker := time.NewTicker(collectInterval(interval))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			rp.Report()
+		case <-rp.stop:
+			return
+		}
+	}
+}
+
+// Stop stops reporting, the metrics are not reported once more.
+func (rp *Reporter) Stop() {
+	rp.stopOnce.Do(func() { close(rp.stop) })
+	rp.wg.Wait()
+}
+
+// Report writes a snapshot of the registry now.
+func (rp *Reporter) Report() {
+	if metrics := rp.snapshot(time.Now().UTC()); len(metrics) > 0 {
+		rp.writer.Write(metrics)
+	}
+}
+
+// snapshot converts the registry to metrics according to the options,
+// children of vectors become separate series of the same measurement
+func (rp *Reporter) snapshot(tm time.Time) []Metric {
+	var metrics []Metric
+	series := make(map[string]runtimeMetric)
+	values := func(measurement string, tags map[string]string) map[string]interface{} {
+		key := seriesKey(measurement, tags)
+		m, ok := series[key]
+		if !ok {
+			m = runtimeMetric{
+				measurement: measurement,
+				tags:        rp.tags(tags),
+				values:      make(map[string]interface{}),
+				time:        tm,
+			}
+			series[key] = m
+			metrics = append(metrics, m)
+		}
+		return m.values
+	}
+
+	add := func(name string, tags map[string]string, metric interface{}) {
+		switch rp.opts.Mode {
+		case ReportSingle:
+			metricValues(values(rp.opts.Measurement, tags), name, metric)
+		case ReportByPrefix:
+			i := strings.LastIndex(name, rp.opts.Separator)
+			if i < 0 && rp.opts.Measurement == "" {
+				// the measurement can't be empty
+				metricValues(values(name, tags), "", metric)
+				return
+			}
+			measurement, field := "", name
+			if i >= 0 {
+				measurement, field = name[:i], name[i+len(rp.opts.Separator):]
+			}
+			metricValues(values(rp.measurement(measurement), tags), field, metric)
+		default:
+			metricValues(values(rp.measurement(name), tags), "", metric)
+		}
+	}
+
+	rp.registry.Each(func(name string, metric interface{}) {
+		if v, ok := metric.(vector); ok {
+			v.each(func(tags map[string]string, child interface{}) {
+				add(name, tags, child)
+			})
+			return
+		}
+		add(name, nil, metric)
+	})
+
+	ret := metrics[:0]
+	for _, m := range metrics {
+		if len(m.Values()) > 0 {
+			ret = append(ret, m)
+		}
+	}
+	return ret
+}
+
+// seriesKey identifies the measurement with the tag set
+func seriesKey(measurement string, tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString(measurement)
+	for _, k := range keys {
+		b.WriteString("\xff" + k + "=" + tags[k])
+	}
+	return b.String()
+}
+
+// measurement prefixes the name with the configured measurement
+func (rp *Reporter) measurement(name string) string {
+	switch {
+	case rp.opts.Measurement == "":
+		return name
+	case name == "":
+		return rp.opts.Measurement
+	}
+	return rp.opts.Measurement + rp.opts.Separator + name
+}
+
+// tags merges the common tags with the tags of the series into a new map
+func (rp *Reporter) tags(seriesTags map[string]string) map[string]string {
+	if len(rp.opts.Tags) == 0 && len(seriesTags) == 0 {
+		return nil
+	}
+	tags := make(map[string]string, len(rp.opts.Tags)+len(seriesTags))
+	for k, v := range rp.opts.Tags {
+		tags[k] = v
+	}
+	for k, v := range seriesTags {
+		tags[k] = v
+	}
+	return tags
+}
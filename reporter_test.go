@@ -0,0 +1,116 @@
+package influx
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testRegistry() Registry {
+	r := NewRegistry()
+	r.GetOrRegister("pool.shares.accepted", NewCounter).(Counter).Inc(3)
+	r.GetOrRegister("pool.shares.difficulty", NewGauge).(Gauge).Update(1024)
+	r.GetOrRegister("pool.latency", func() Histogram { return NewHistogram(NewUniformSample(10)) }).(Histogram).Update(5)
+	return r
+}
+
+func metricsByName(metrics []Metric) map[string]Metric {
+	ret := make(map[string]Metric)
+	for _, m := range metrics {
+		ret[m.Measurement()] = m
+	}
+	return ret
+}
+
+func TestReporterModes(t *testing.T) {
+	tm := time.Now()
+	rp := &Reporter{registry: testRegistry(), opts: ReporterOptions{Mode: ReportPerMetric, Separator: ".", Tags: map[string]string{"coin": "btc"}}}
+	metrics := metricsByName(rp.snapshot(tm))
+	if assert.Len(t, metrics, 3) {
+		assert.Equal(t, map[string]interface{}{"count": int64(3)}, metrics["pool.shares.accepted"].Values())
+		assert.Equal(t, map[string]interface{}{"value": int64(1024)}, metrics["pool.shares.difficulty"].Values())
+		assert.Equal(t, int64(5), metrics["pool.latency"].Values()["max"])
+		assert.Equal(t, map[string]string{"coin": "btc"}, metrics["pool.latency"].Tags())
+		assert.Equal(t, tm, metrics["pool.latency"].Time())
+	}
+
+	rp.opts.Mode = ReportByPrefix
+	rp.opts.Measurement = "stratum"
+	metrics = metricsByName(rp.snapshot(tm))
+	if assert.Len(t, metrics, 2) {
+		assert.Equal(t, map[string]interface{}{"accepted": int64(3), "difficulty": int64(1024)}, metrics["stratum.pool.shares"].Values())
+		assert.Equal(t, 5.0, metrics["stratum.pool"].Values()["latency.p99"])
+	}
+
+	// a name without separator needs a measurement
+	rp.registry.GetOrRegister("uptime", NewGauge).(Gauge).Update(60)
+	rp.opts.Measurement = ""
+	metrics = metricsByName(rp.snapshot(tm))
+	if assert.Len(t, metrics, 3) {
+		assert.Equal(t, map[string]interface{}{"value": int64(60)}, metrics["uptime"].Values())
+		_, err := newPoint(nil, metrics["uptime"])
+		assert.NoError(t, err)
+	}
+	rp.opts.Measurement = "stratum"
+	rp.registry.Unregister("uptime")
+
+	rp.opts.Mode = ReportSingle
+	metrics = metricsByName(rp.snapshot(tm))
+	if assert.Len(t, metrics, 1) {
+		assert.Equal(t, int64(3), metrics["stratum"].Values()["pool.shares.accepted"])
+		assert.Equal(t, int64(1), metrics["stratum"].Values()["pool.latency.count"])
+	}
+}
+
+func TestReporter(t *testing.T) {
+	var mutex sync.Mutex
+	var lines []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mutex.Lock()
+		lines = append(lines, strings.Split(strings.TrimSpace(string(body)), "\n")...)
+		mutex.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	w, err := NewWriter(Config{Endpoint: srv.URL, Host: "pool1"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	rp := NewReporter(testRegistry(), w, 10*time.Millisecond, ReporterOptions{Mode: ReportByPrefix})
+	time.Sleep(25 * time.Millisecond)
+	rp.Stop()
+	NewReporter(testRegistry(), w, 0, ReporterOptions{}).Stop() // the default interval is used
+	assert.NoError(t, w.Close())
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	sort.Strings(lines)
+	if assert.True(t, len(lines) >= 4, "%v", lines) {
+		assert.True(t, strings.HasPrefix(lines[0], "pool,host=pool1 "), lines[0])
+		assert.True(t, strings.HasPrefix(lines[len(lines)-1], "pool.shares,host=pool1 accepted=3i,difficulty=1024i "), lines[len(lines)-1])
+	}
+}
+
+func TestReporterSingleDefault(t *testing.T) {
+	var b bytes.Buffer
+	w, err := NewWriter(Config{Sink: NewLineSink(&b), Host: "pool1", BatchInterval: "1h"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	rp := NewReporter(testRegistry(), w, time.Hour, ReporterOptions{Mode: ReportSingle})
+	assert.Equal(t, reportMeasurement, rp.opts.Measurement)
+	rp.Report()
+	rp.Stop()
+	assert.NoError(t, w.Close())
+	assert.True(t, strings.HasPrefix(b.String(), "metrics,host=pool1 "), b.String())
+}
@@ -0,0 +1,95 @@
+package influx
+
+import (
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	defaultRetryBaseBackoff = 100 * time.Millisecond
+	defaultRetryMaxBackoff  = 10 * time.Second
+)
+
+// permanentErrors are parts of influx responses for writes which fail the same way
+// however many times they are repeated
+var permanentErrors = []string{
+	"partial write",
+	"field type conflict",
+	"unable to parse",
+	"database not found",
+	"retention policy not found",
+	"authorization failed",
+	"max-values-per-tag limit exceeded",
+	"max-series-per-database limit exceeded",
+}
+
+type retryPolicy struct {
+	maxAttempts int
+	baseBackoff time.Duration
+	maxBackoff  time.Duration
+	jitter      float64
+}
+
+func newRetryPolicy(cfg Config) retryPolicy {
+	p := retryPolicy{
+		maxAttempts: cfg.RetryMaxAttempts,
+		baseBackoff: mustParseDuration(cfg.RetryBaseBackoff),
+		maxBackoff:  mustParseDuration(cfg.RetryMaxBackoff),
+		jitter:      cfg.RetryJitter,
+	}
+	if p.maxAttempts < 1 {
+		p.maxAttempts = 1
+	}
+	if p.maxBackoff < p.baseBackoff {
+		p.maxBackoff = p.baseBackoff
+	}
+	return p
+}
+
+// backoff returns the delay before the given retry, retries are numbered from 1
+func (p retryPolicy) backoff(retry int) time.Duration {
+	d := p.baseBackoff
+	for i := 1; i < retry && d < p.maxBackoff; i++ {
+		d *= 2
+	}
+	if d > p.maxBackoff {
+		d = p.maxBackoff
+	}
+	if p.jitter > 0 {
+		d -= time.Duration(rand.Float64() * p.jitter * float64(d))
+	}
+	return d
+}
+
+// isRetryable reports whether a failed write may succeed if repeated later:
+// network failures and server side errors are, rejected points are not
+func isRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	// follow the wrapping of the errors of this package without errors.As,
+	// which needs go1.13
+	for e := err; e != nil; {
+		switch e := e.(type) {
+		case net.Error:
+			return true
+		case *HTTPError:
+			return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+		}
+		u, ok := e.(interface{ Unwrap() error })
+		if !ok {
+			break
+		}
+		e = u.Unwrap()
+	}
+	msg := err.Error()
+	for _, permanent := range permanentErrors {
+		if strings.Contains(msg, permanent) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package influx
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetry(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&requests, 1) {
+		case 1, 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"error":"timeout"}`))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	w, err := NewWriter(Config{
+		Endpoint:         srv.URL,
+		BatchInterval:    "1h",
+		Precision:        "s",
+		RetryMaxAttempts: 3,
+		RetryBaseBackoff: "1ms",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	w.Write(SimpleMetric{Name: "retry", ValuesMap: map[string]interface{}{"value": 1}, CreateTime: time.Now()})
+	assert.NoError(t, w.Close())
+	assert.EqualValues(t, 3, atomic.LoadInt32(&requests))
+}
+
+func TestRetryPermanent(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"partial write: field type conflict"}`))
+	}))
+	defer srv.Close()
+
+	var handled []error
+	w, err := NewWriter(Config{
+		Endpoint:         srv.URL,
+		BatchInterval:    "1h",
+		Precision:        "s",
+		RetryMaxAttempts: 3,
+		RetryBaseBackoff: "1ms",
+		ErrorHandler:     ErrorHandlerFunc(func(err error) { handled = append(handled, err) }),
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	w.Write(SimpleMetric{Name: "retry", ValuesMap: map[string]interface{}{"value": 1}, CreateTime: time.Now()})
+	assert.NoError(t, w.Close())
+	assert.EqualValues(t, 1, atomic.LoadInt32(&requests))
+	if assert.Len(t, handled, 1) {
+		writeErr, ok := handled[0].(*WriteError)
+		if assert.True(t, ok, "%T", handled[0]) {
+			assert.Equal(t, 1, writeErr.Points)
+			assert.Equal(t, 1, writeErr.Attempts)
+			assert.False(t, writeErr.Retry)
+			assert.False(t, writeErr.Spooled)
+		}
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	p := newRetryPolicy(Config{RetryMaxAttempts: 5, RetryBaseBackoff: "1s", RetryMaxBackoff: "3s"})
+	assert.Equal(t, time.Second, p.backoff(1))
+	assert.Equal(t, 2*time.Second, p.backoff(2))
+	assert.Equal(t, 3*time.Second, p.backoff(3))
+	assert.Equal(t, 3*time.Second, p.backoff(10))
+
+	assert.False(t, isRetryable(errors.New("unable to parse 'foo': missing fields")))
+	assert.True(t, isRetryable(errors.New("engine: cache-max-memory-size exceeded")))
+	// errors wrapped by this package are unwrapped
+	assert.False(t, isRetryable(&WriteError{Err: &HTTPError{StatusCode: http.StatusBadRequest, Message: "timeout"}}))
+	assert.True(t, isRetryable(&WriteError{Err: &HTTPError{StatusCode: http.StatusServiceUnavailable}}))
+}
@@ -0,0 +1,75 @@
+package influx
+
+import (
+	"path"
+
+	"github.com/influxdata/influxdb/client/v2"
+)
+
+// DatabaseRouter is implemented by metrics which are written to a database
+// other than the writer one, for InfluxDB 2.x it is the bucket.
+type DatabaseRouter interface {
+	Database() string
+}
+
+// RetentionPolicy is implemented by metrics which are written to a
+// retention policy other than the writer one.
+type RetentionPolicy interface {
+	RetentionPolicy() string
+}
+
+// Route sends metrics with the measurement matching Pattern to Database and
+// RetentionPolicy, empty values keep the writer ones. Pattern is matched by
+// path.Match, so "pool.*" or "shares_[ab]" can be used.
+type Route struct {
+	Pattern         string `json:"pattern"`
+	Database        string `json:"database"`
+	RetentionPolicy string `json:"retention_policy"`
+}
+
+// batchKey identifies the batch of a worker, there is one per database and retention policy
+type batchKey struct {
+	database        string
+	retentionPolicy string
+}
+
+// routedPoint is a point with the batch it goes to
+type routedPoint struct {
+	point *client.Point
+	key   batchKey
+}
+
+// route returns the batch of the metric, the interfaces implemented by the
+// metric take precedence over the first matching route
+func (s *Writer) route(m Metric) batchKey {
+	key := batchKey{database: s.database, retentionPolicy: s.retentionPolicy}
+	for _, r := range s.routes {
+		if ok, _ := path.Match(r.Pattern, m.Measurement()); ok {
+			if r.Database != "" {
+				key.database = r.Database
+			}
+			if r.RetentionPolicy != "" {
+				key.retentionPolicy = r.RetentionPolicy
+			}
+			break
+		}
+	}
+	if d, ok := m.(DatabaseRouter); ok && d.Database() != "" {
+		key.database = d.Database()
+	}
+	if rp, ok := m.(RetentionPolicy); ok && rp.RetentionPolicy() != "" {
+		key.retentionPolicy = rp.RetentionPolicy()
+	}
+	return key
+}
+
+// newBatch creates an empty batch for the key with the writer precision and consistency
+func (s *Writer) newBatch(key batchKey) client.BatchPoints {
+	c, _ := client.NewBatchPoints(client.BatchPointsConfig{
+		Database:         key.database,
+		RetentionPolicy:  key.retentionPolicy,
+		Precision:        s.Precision,
+		WriteConsistency: s.writeConsistency,
+	})
+	return c
+}
@@ -0,0 +1,88 @@
+package influx
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type routedMetric struct {
+	SimpleMetric
+	db, rp string
+}
+
+func (m routedMetric) Database() string {
+	return m.db
+}
+
+func (m routedMetric) RetentionPolicy() string {
+	return m.rp
+}
+
+func TestRouting(t *testing.T) {
+	var mutex sync.Mutex
+	requests := make(map[string][]string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		assert.Equal(t, "quorum", q.Get("consistency"))
+		body, _ := ioutil.ReadAll(r.Body)
+		mutex.Lock()
+		key := q.Get("db") + "/" + q.Get("rp")
+		for _, line := range strings.Split(strings.TrimSpace(string(body)), "\n") {
+			requests[key] = append(requests[key], strings.Fields(line)[0])
+		}
+		mutex.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	_, err := NewWriter(Config{Endpoint: srv.URL, WriteConsistency: "most", Routes: []Route{{Pattern: "[a-"}}})
+	if assert.IsType(t, ConfigError{}, err) {
+		assert.Len(t, err.(ConfigError), 2)
+	}
+
+	w, err := NewWriter(Config{
+		Endpoint:         srv.URL,
+		Database:         "metrics",
+		RetentionPolicy:  "autogen",
+		WriteConsistency: "quorum",
+		BatchInterval:    "1h",
+		Routes: []Route{
+			{Pattern: "pool.*", Database: "pool"},
+			{Pattern: "pool.blocks", Database: "blocks"}, // the first match wins
+			{Pattern: "billing", RetentionPolicy: "forever"},
+		},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	metric := func(name string) SimpleMetric {
+		return SimpleMetric{Name: name, ValuesMap: map[string]interface{}{"value": 1}}
+	}
+	w.Write([]Metric{
+		metric("runtime"),
+		metric("pool.shares"),
+		metric("pool.blocks"),
+		metric("billing"),
+		routedMetric{SimpleMetric: metric("pool.payouts"), db: "payouts"},
+		routedMetric{SimpleMetric: metric("billing.audit"), rp: "year"},
+	})
+	assert.NoError(t, w.Flush(context.Background()))
+	assert.NoError(t, w.Close())
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	assert.Equal(t, map[string][]string{
+		"metrics/autogen": {"runtime"},
+		"pool/autogen":    {"pool.shares", "pool.blocks"},
+		"metrics/forever": {"billing"},
+		"payouts/autogen": {"pool.payouts"},
+		"metrics/year":    {"billing.audit"},
+	}, requests)
+}
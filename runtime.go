@@ -1,13 +1,19 @@
 package influx
 
 import (
-	"log"
+	"fmt"
 	"runtime"
+	"strings"
+	"sync"
+	"time"
 )
 
-var (
-	memStats       runtime.MemStats
-	runtimeMetrics struct {
+// runtimeStats keeps the runtime metrics and the previous values of the
+// cumulative counters, which are reported as deltas
+type runtimeStats struct {
+	mutex    sync.Mutex
+	memStats runtime.MemStats
+	metrics  struct {
 		MemStats struct {
 			Alloc        Gauge
 			BuckHashSys  Gauge
@@ -29,7 +35,7 @@ var (
 			MSpanSys     Gauge
 			NextGC       Gauge
 			NumGC        Gauge
-			// PauseNs      Histogram
+			PauseNs      Histogram
 			PauseTotalNs Gauge
 			StackInuse   Gauge
 			StackSys     Gauge
@@ -38,204 +44,280 @@ var (
 		}
 		NumCgoCall   Gauge
 		NumGoroutine Gauge
-		// ReadMemStats Timer
+		ReadMemStats Timer
 	}
 	frees   uint64
 	lookups uint64
 	mallocs uint64
 	numGC   uint32
+
+	numCgoCall int64
+	reader     *runtimeMetricsReader // nil unless runtime/metrics are read
+}
+
+var (
+	// registryStats keeps the state of registries given to RegisterRuntimeMemStats
+	registryStats      = make(map[Registry]*runtimeStats)
+	registryStatsMutex sync.Mutex
 )
 
 // CaptureRuntimeMemStatsOnce new values for the Go runtime statistics exported in
 // runtime.MemStats.  This is designed to be called in a background
-// goroutine.  Giving a registry which has not been given to
-// RegisterRuntimeMemStats will panic.
+// goroutine.  A registry which has not been given to
+// RegisterRuntimeMemStats is reported to DefaultErrorHandler.
 //
 // Be very careful with this because runtime.ReadMemStats calls the C
 // functions runtime·semacquire(&runtime·worldsema) and runtime·stoptheworld()
 // and that last one does what it says on the tin.
 func CaptureRuntimeMemStatsOnce(r Registry) {
-	// t := time.Now()
-	runtime.ReadMemStats(&memStats) // This takes 50-200us.
-	// runtimeMetrics.ReadMemStats.UpdateSince(t)
-
-	runtimeMetrics.MemStats.Alloc.Update(int64(memStats.Alloc))
-	runtimeMetrics.MemStats.BuckHashSys.Update(int64(memStats.BuckHashSys))
-	if memStats.DebugGC {
-		runtimeMetrics.MemStats.DebugGC.Update(1)
+	registryStatsMutex.Lock()
+	rs, ok := registryStats[r]
+	registryStatsMutex.Unlock()
+	if !ok {
+		DefaultErrorHandler.HandleError(fmt.Errorf("influx: runtime stats of the registry are not registered"))
+		return
+	}
+	rs.capture()
+}
+
+// capture reads runtime.MemStats, or runtime/metrics if the reader is
+// set, and updates the metrics
+func (rs *runtimeStats) capture() {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+	var pauses []uint64
+	if rs.reader != nil {
+		pauses = rs.reader.read(&rs.memStats) // doesn't stop the world
 	} else {
-		runtimeMetrics.MemStats.DebugGC.Update(0)
+		t := time.Now()
+		runtime.ReadMemStats(&rs.memStats) // This takes 50-200us.
+		rs.metrics.ReadMemStats.UpdateSince(t)
+		pauses = gcPauses(&rs.memStats, rs.numGC)
 	}
-	if memStats.EnableGC {
-		runtimeMetrics.MemStats.EnableGC.Update(1)
+
+	rs.metrics.MemStats.Alloc.Update(int64(rs.memStats.Alloc))
+	rs.metrics.MemStats.BuckHashSys.Update(int64(rs.memStats.BuckHashSys))
+	if rs.memStats.DebugGC {
+		rs.metrics.MemStats.DebugGC.Update(1)
 	} else {
-		runtimeMetrics.MemStats.EnableGC.Update(0)
-	}
-
-	runtimeMetrics.MemStats.Frees.Update(int64(memStats.Frees - frees))
-	runtimeMetrics.MemStats.HeapAlloc.Update(int64(memStats.HeapAlloc))
-	runtimeMetrics.MemStats.HeapIdle.Update(int64(memStats.HeapIdle))
-	runtimeMetrics.MemStats.HeapInuse.Update(int64(memStats.HeapInuse))
-	runtimeMetrics.MemStats.HeapObjects.Update(int64(memStats.HeapObjects))
-	runtimeMetrics.MemStats.HeapReleased.Update(int64(memStats.HeapReleased))
-	runtimeMetrics.MemStats.HeapSys.Update(int64(memStats.HeapSys))
-	runtimeMetrics.MemStats.LastGC.Update(int64(memStats.LastGC))
-	runtimeMetrics.MemStats.Lookups.Update(int64(memStats.Lookups - lookups))
-	runtimeMetrics.MemStats.Mallocs.Update(int64(memStats.Mallocs - mallocs))
-	runtimeMetrics.MemStats.MCacheInuse.Update(int64(memStats.MCacheInuse))
-	runtimeMetrics.MemStats.MCacheSys.Update(int64(memStats.MCacheSys))
-	runtimeMetrics.MemStats.MSpanInuse.Update(int64(memStats.MSpanInuse))
-	runtimeMetrics.MemStats.MSpanSys.Update(int64(memStats.MSpanSys))
-	runtimeMetrics.MemStats.NextGC.Update(int64(memStats.NextGC))
-	runtimeMetrics.MemStats.NumGC.Update(int64(memStats.NumGC - numGC))
-
-	// <https://code.google.com/p/go/source/browse/src/pkg/runtime/mgc0.c>
-	// i := numGC % uint32(len(memStats.PauseNs))
-	// ii := memStats.NumGC % uint32(len(memStats.PauseNs))
-	// if memStats.NumGC-numGC >= uint32(len(memStats.PauseNs)) {
-	// 	for i = 0; i < uint32(len(memStats.PauseNs)); i++ {
-	// 		runtimeMetrics.MemStats.PauseNs.Update(int64(memStats.PauseNs[i]))
-	// 	}
-	// } else {
-	// 	if i > ii {
-	// 		for ; i < uint32(len(memStats.PauseNs)); i++ {
-	// 			runtimeMetrics.MemStats.PauseNs.Update(int64(memStats.PauseNs[i]))
-	// 		}
-	// 		i = 0
-	// 	}
-	// 	for ; i < ii; i++ {
-	// 		runtimeMetrics.MemStats.PauseNs.Update(int64(memStats.PauseNs[i]))
-	// 	}
-	// }
-	frees = memStats.Frees
-	lookups = memStats.Lookups
-	mallocs = memStats.Mallocs
-	numGC = memStats.NumGC
-
-	runtimeMetrics.MemStats.PauseTotalNs.Update(int64(memStats.PauseTotalNs))
-	runtimeMetrics.MemStats.StackInuse.Update(int64(memStats.StackInuse))
-	runtimeMetrics.MemStats.StackSys.Update(int64(memStats.StackSys))
-	runtimeMetrics.MemStats.Sys.Update(int64(memStats.Sys))
-	runtimeMetrics.MemStats.TotalAlloc.Update(int64(memStats.TotalAlloc))
-
-	runtimeMetrics.NumGoroutine.Update(int64(runtime.NumGoroutine()))
+		rs.metrics.MemStats.DebugGC.Update(0)
+	}
+	if rs.memStats.EnableGC {
+		rs.metrics.MemStats.EnableGC.Update(1)
+	} else {
+		rs.metrics.MemStats.EnableGC.Update(0)
+	}
+
+	rs.metrics.MemStats.Frees.Update(int64(rs.memStats.Frees - rs.frees))
+	rs.metrics.MemStats.HeapAlloc.Update(int64(rs.memStats.HeapAlloc))
+	rs.metrics.MemStats.HeapIdle.Update(int64(rs.memStats.HeapIdle))
+	rs.metrics.MemStats.HeapInuse.Update(int64(rs.memStats.HeapInuse))
+	rs.metrics.MemStats.HeapObjects.Update(int64(rs.memStats.HeapObjects))
+	rs.metrics.MemStats.HeapReleased.Update(int64(rs.memStats.HeapReleased))
+	rs.metrics.MemStats.HeapSys.Update(int64(rs.memStats.HeapSys))
+	rs.metrics.MemStats.LastGC.Update(int64(rs.memStats.LastGC))
+	rs.metrics.MemStats.Lookups.Update(int64(rs.memStats.Lookups - rs.lookups))
+	rs.metrics.MemStats.Mallocs.Update(int64(rs.memStats.Mallocs - rs.mallocs))
+	rs.metrics.MemStats.MCacheInuse.Update(int64(rs.memStats.MCacheInuse))
+	rs.metrics.MemStats.MCacheSys.Update(int64(rs.memStats.MCacheSys))
+	rs.metrics.MemStats.MSpanInuse.Update(int64(rs.memStats.MSpanInuse))
+	rs.metrics.MemStats.MSpanSys.Update(int64(rs.memStats.MSpanSys))
+	rs.metrics.MemStats.NextGC.Update(int64(rs.memStats.NextGC))
+	rs.metrics.MemStats.NumGC.Update(int64(rs.memStats.NumGC - rs.numGC))
+
+	for _, pause := range pauses {
+		rs.metrics.MemStats.PauseNs.Update(int64(pause))
+	}
+	rs.frees = rs.memStats.Frees
+	rs.lookups = rs.memStats.Lookups
+	rs.mallocs = rs.memStats.Mallocs
+	rs.numGC = rs.memStats.NumGC
+
+	numCgoCall := runtime.NumCgoCall()
+	rs.metrics.NumCgoCall.Update(numCgoCall - rs.numCgoCall)
+	rs.numCgoCall = numCgoCall
+
+	rs.metrics.MemStats.PauseTotalNs.Update(int64(rs.memStats.PauseTotalNs))
+	rs.metrics.MemStats.StackInuse.Update(int64(rs.memStats.StackInuse))
+	rs.metrics.MemStats.StackSys.Update(int64(rs.memStats.StackSys))
+	rs.metrics.MemStats.Sys.Update(int64(rs.memStats.Sys))
+	rs.metrics.MemStats.TotalAlloc.Update(int64(rs.memStats.TotalAlloc))
+
+	rs.metrics.NumGoroutine.Update(int64(runtime.NumGoroutine()))
+}
+
+// gcPauses returns the pauses of the collections made since prevNumGC was
+// read. PauseNs is a circular buffer where the pause of collection n is at
+// (n+255)%256, only the last 256 pauses are kept.
+func gcPauses(ms *runtime.MemStats, prevNumGC uint32) []uint64 {
+	size := uint32(len(ms.PauseNs))
+	n := ms.NumGC - prevNumGC // wraps around together with NumGC
+	if n > size {
+		n = size
+	}
+	pauses := make([]uint64, 0, n)
+	for gc := ms.NumGC - n; gc != ms.NumGC; gc++ {
+		pauses = append(pauses, ms.PauseNs[gc%size])
+	}
+	return pauses
 }
 
 // RegisterRuntimeMemStats runtimeMetrics for the Go runtime statistics exported in runtime and
 // specifically runtime.MemStats.  The runtimeMetrics are named by their
 // fully-qualified Go symbols, i.e. runtime.MemStats.Alloc.
 func RegisterRuntimeMemStats(r Registry) {
-	runtimeMetrics.MemStats.Alloc = NewGauge()
-	runtimeMetrics.MemStats.BuckHashSys = NewGauge()
-	runtimeMetrics.MemStats.DebugGC = NewGauge()
-	runtimeMetrics.MemStats.EnableGC = NewGauge()
-	runtimeMetrics.MemStats.Frees = NewGauge()
-	runtimeMetrics.MemStats.HeapAlloc = NewGauge()
-	runtimeMetrics.MemStats.HeapIdle = NewGauge()
-	runtimeMetrics.MemStats.HeapInuse = NewGauge()
-	runtimeMetrics.MemStats.HeapObjects = NewGauge()
-	runtimeMetrics.MemStats.HeapReleased = NewGauge()
-	runtimeMetrics.MemStats.HeapSys = NewGauge()
-	runtimeMetrics.MemStats.LastGC = NewGauge()
-	runtimeMetrics.MemStats.Lookups = NewGauge()
-	runtimeMetrics.MemStats.Mallocs = NewGauge()
-	runtimeMetrics.MemStats.MCacheInuse = NewGauge()
-	runtimeMetrics.MemStats.MCacheSys = NewGauge()
-	runtimeMetrics.MemStats.MSpanInuse = NewGauge()
-	runtimeMetrics.MemStats.MSpanSys = NewGauge()
-	runtimeMetrics.MemStats.NextGC = NewGauge()
-	runtimeMetrics.MemStats.NumGC = NewGauge()
-	// runtimeMetrics.MemStats.PauseNs = NewHistogram(NewExpDecaySample(1028, 0.015))
-	runtimeMetrics.MemStats.PauseTotalNs = NewGauge()
-	runtimeMetrics.MemStats.StackInuse = NewGauge()
-	runtimeMetrics.MemStats.StackSys = NewGauge()
-	runtimeMetrics.MemStats.Sys = NewGauge()
-	runtimeMetrics.MemStats.TotalAlloc = NewGauge()
-	runtimeMetrics.NumCgoCall = NewGauge()
-	runtimeMetrics.NumGoroutine = NewGauge()
-	// runtimeMetrics.ReadMemStats = NewTimer()
+	rs := newRuntimeStats(r)
+	registryStatsMutex.Lock()
+	registryStats[r] = rs
+	registryStatsMutex.Unlock()
+}
+
+// UnregisterRuntimeMemStats removes the runtime metrics registered by
+// RegisterRuntimeMemStats from the registry and forgets its state.
+func UnregisterRuntimeMemStats(r Registry) {
+	registryStatsMutex.Lock()
+	rs, ok := registryStats[r]
+	delete(registryStats, r)
+	registryStatsMutex.Unlock()
+	if ok {
+		rs.release(r)
+	}
+}
+
+// release unregisters the runtime metrics from the registry, which stops
+// the meter of ReadMemStats timer
+func (rs *runtimeStats) release(r Registry) {
+	var names []string
+	r.Each(func(name string, i interface{}) {
+		if strings.HasPrefix(name, "runtime.") {
+			names = append(names, name)
+		}
+	})
+	for _, name := range names {
+		r.Unregister(name)
+	}
+	rs.metrics.ReadMemStats.Stop() // in case the registry doesn't stop it
+}
+
+// newRuntimeStats creates the runtime metrics and registers them in the registry
+func newRuntimeStats(r Registry) *runtimeStats {
+	rs := &runtimeStats{}
+	rs.metrics.MemStats.Alloc = NewGauge()
+	rs.metrics.MemStats.BuckHashSys = NewGauge()
+	rs.metrics.MemStats.DebugGC = NewGauge()
+	rs.metrics.MemStats.EnableGC = NewGauge()
+	rs.metrics.MemStats.Frees = NewGauge()
+	rs.metrics.MemStats.HeapAlloc = NewGauge()
+	rs.metrics.MemStats.HeapIdle = NewGauge()
+	rs.metrics.MemStats.HeapInuse = NewGauge()
+	rs.metrics.MemStats.HeapObjects = NewGauge()
+	rs.metrics.MemStats.HeapReleased = NewGauge()
+	rs.metrics.MemStats.HeapSys = NewGauge()
+	rs.metrics.MemStats.LastGC = NewGauge()
+	rs.metrics.MemStats.Lookups = NewGauge()
+	rs.metrics.MemStats.Mallocs = NewGauge()
+	rs.metrics.MemStats.MCacheInuse = NewGauge()
+	rs.metrics.MemStats.MCacheSys = NewGauge()
+	rs.metrics.MemStats.MSpanInuse = NewGauge()
+	rs.metrics.MemStats.MSpanSys = NewGauge()
+	rs.metrics.MemStats.NextGC = NewGauge()
+	rs.metrics.MemStats.NumGC = NewGauge()
+	rs.metrics.MemStats.PauseNs = NewHistogram(NewExpDecaySample(1028, 0.015))
+	rs.metrics.MemStats.PauseTotalNs = NewGauge()
+	rs.metrics.MemStats.StackInuse = NewGauge()
+	rs.metrics.MemStats.StackSys = NewGauge()
+	rs.metrics.MemStats.Sys = NewGauge()
+	rs.metrics.MemStats.TotalAlloc = NewGauge()
+	rs.metrics.NumCgoCall = NewGauge()
+	rs.metrics.NumGoroutine = NewGauge()
+	rs.metrics.ReadMemStats = NewTimer()
 
-	if err := r.Register("runtime.MemStats.Alloc", runtimeMetrics.MemStats.Alloc); err != nil {
-		log.Printf("[ERROR] registering runtime.MemStats.Alloc %v", err)
+	if err := r.Register("runtime.MemStats.Alloc", rs.metrics.MemStats.Alloc); err != nil {
+		DefaultErrorHandler.HandleError(err)
+	}
+	if err := r.Register("runtime.MemStats.BuckHashSys", rs.metrics.MemStats.BuckHashSys); err != nil {
+		DefaultErrorHandler.HandleError(err)
+	}
+	if err := r.Register("runtime.MemStats.DebugGC", rs.metrics.MemStats.DebugGC); err != nil {
+		DefaultErrorHandler.HandleError(err)
 	}
-	if err := r.Register("runtime.MemStats.BuckHashSys", runtimeMetrics.MemStats.BuckHashSys); err != nil {
-		log.Printf("[ERROR] registering runtime.MemStats.BuckHashSys %v", err)
+	if err := r.Register("runtime.MemStats.EnableGC", rs.metrics.MemStats.EnableGC); err != nil {
+		DefaultErrorHandler.HandleError(err)
 	}
-	if err := r.Register("runtime.MemStats.DebugGC", runtimeMetrics.MemStats.DebugGC); err != nil {
-		log.Printf("[ERROR] registering runtime.MemStats.DebugGC %v", err)
+	if err := r.Register("runtime.MemStats.Frees", rs.metrics.MemStats.Frees); err != nil {
+		DefaultErrorHandler.HandleError(err)
 	}
-	if err := r.Register("runtime.MemStats.EnableGC", runtimeMetrics.MemStats.EnableGC); err != nil {
-		log.Printf("[ERROR] registering runtime.MemStats.EnableGC %v", err)
+	if err := r.Register("runtime.MemStats.HeapAlloc", rs.metrics.MemStats.HeapAlloc); err != nil {
+		DefaultErrorHandler.HandleError(err)
 	}
-	if err := r.Register("runtime.MemStats.Frees", runtimeMetrics.MemStats.Frees); err != nil {
-		log.Printf("[ERROR] registering runtime.MemStats.Frees %v", err)
+	if err := r.Register("runtime.MemStats.HeapIdle", rs.metrics.MemStats.HeapIdle); err != nil {
+		DefaultErrorHandler.HandleError(err)
 	}
-	if err := r.Register("runtime.MemStats.HeapAlloc", runtimeMetrics.MemStats.HeapAlloc); err != nil {
-		log.Printf("[ERROR] registering runtime.MemStats.HeapAlloc %v", err)
+	if err := r.Register("runtime.MemStats.HeapInuse", rs.metrics.MemStats.HeapInuse); err != nil {
+		DefaultErrorHandler.HandleError(err)
 	}
-	if err := r.Register("runtime.MemStats.HeapIdle", runtimeMetrics.MemStats.HeapIdle); err != nil {
-		log.Printf("[ERROR] registering runtime.MemStats.HeapIdle %v", err)
+	if err := r.Register("runtime.MemStats.HeapObjects", rs.metrics.MemStats.HeapObjects); err != nil {
+		DefaultErrorHandler.HandleError(err)
 	}
-	if err := r.Register("runtime.MemStats.HeapInuse", runtimeMetrics.MemStats.HeapInuse); err != nil {
-		log.Printf("[ERROR] registering runtime.MemStats.HeapInuse %v", err)
+	if err := r.Register("runtime.MemStats.HeapReleased", rs.metrics.MemStats.HeapReleased); err != nil {
+		DefaultErrorHandler.HandleError(err)
 	}
-	if err := r.Register("runtime.MemStats.HeapObjects", runtimeMetrics.MemStats.HeapObjects); err != nil {
-		log.Printf("[ERROR] registering runtime.MemStats.HeapObjects %v", err)
+	if err := r.Register("runtime.MemStats.HeapSys", rs.metrics.MemStats.HeapSys); err != nil {
+		DefaultErrorHandler.HandleError(err)
 	}
-	if err := r.Register("runtime.MemStats.HeapReleased", runtimeMetrics.MemStats.HeapReleased); err != nil {
-		log.Printf("[ERROR] registering runtime.MemStats.HeapReleased %v", err)
+	if err := r.Register("runtime.MemStats.LastGC", rs.metrics.MemStats.LastGC); err != nil {
+		DefaultErrorHandler.HandleError(err)
 	}
-	if err := r.Register("runtime.MemStats.HeapSys", runtimeMetrics.MemStats.HeapSys); err != nil {
-		log.Printf("[ERROR] registering runtime.MemStats.HeapSys %v", err)
+	if err := r.Register("runtime.MemStats.Lookups", rs.metrics.MemStats.Lookups); err != nil {
+		DefaultErrorHandler.HandleError(err)
 	}
-	if err := r.Register("runtime.MemStats.LastGC", runtimeMetrics.MemStats.LastGC); err != nil {
-		log.Printf("[ERROR] registering runtime.MemStats.LastGC %v", err)
+	if err := r.Register("runtime.MemStats.Mallocs", rs.metrics.MemStats.Mallocs); err != nil {
+		DefaultErrorHandler.HandleError(err)
 	}
-	if err := r.Register("runtime.MemStats.Lookups", runtimeMetrics.MemStats.Lookups); err != nil {
-		log.Printf("[ERROR] registering runtime.MemStats.Lookups %v", err)
+	if err := r.Register("runtime.MemStats.MCacheInuse", rs.metrics.MemStats.MCacheInuse); err != nil {
+		DefaultErrorHandler.HandleError(err)
 	}
-	if err := r.Register("runtime.MemStats.Mallocs", runtimeMetrics.MemStats.Mallocs); err != nil {
-		log.Printf("[ERROR] registering runtime.MemStats.Mallocs %v", err)
+	if err := r.Register("runtime.MemStats.MCacheSys", rs.metrics.MemStats.MCacheSys); err != nil {
+		DefaultErrorHandler.HandleError(err)
 	}
-	if err := r.Register("runtime.MemStats.MCacheInuse", runtimeMetrics.MemStats.MCacheInuse); err != nil {
-		log.Printf("[ERROR] registering runtime.MemStats.MCacheInuse %v", err)
+	if err := r.Register("runtime.MemStats.MSpanInuse", rs.metrics.MemStats.MSpanInuse); err != nil {
+		DefaultErrorHandler.HandleError(err)
 	}
-	if err := r.Register("runtime.MemStats.MCacheSys", runtimeMetrics.MemStats.MCacheSys); err != nil {
-		log.Printf("[ERROR] registering runtime.MemStats.MCacheSys %v", err)
+	if err := r.Register("runtime.MemStats.MSpanSys", rs.metrics.MemStats.MSpanSys); err != nil {
+		DefaultErrorHandler.HandleError(err)
 	}
-	if err := r.Register("runtime.MemStats.MSpanInuse", runtimeMetrics.MemStats.MSpanInuse); err != nil {
-		log.Printf("[ERROR] registering runtime.MemStats.MSpanInuse %v", err)
+	if err := r.Register("runtime.MemStats.NextGC", rs.metrics.MemStats.NextGC); err != nil {
+		DefaultErrorHandler.HandleError(err)
 	}
-	if err := r.Register("runtime.MemStats.MSpanSys", runtimeMetrics.MemStats.MSpanSys); err != nil {
-		log.Printf("[ERROR] registering runtime.MemStats.MSpanSys %v", err)
+	if err := r.Register("runtime.MemStats.NumGC", rs.metrics.MemStats.NumGC); err != nil {
+		DefaultErrorHandler.HandleError(err)
 	}
-	if err := r.Register("runtime.MemStats.NextGC", runtimeMetrics.MemStats.NextGC); err != nil {
-		log.Printf("[ERROR] registering runtime.MemStats.NextGC %v", err)
+	if err := r.Register("runtime.MemStats.PauseNs", rs.metrics.MemStats.PauseNs); err != nil {
+		DefaultErrorHandler.HandleError(err)
 	}
-	if err := r.Register("runtime.MemStats.NumGC", runtimeMetrics.MemStats.NumGC); err != nil {
-		log.Printf("[ERROR] registering runtime.MemStats.NumGC %v", err)
+	if err := r.Register("runtime.MemStats.PauseTotalNs", rs.metrics.MemStats.PauseTotalNs); err != nil {
+		DefaultErrorHandler.HandleError(err)
 	}
-	// r.Register("runtime.MemStats.PauseNs", runtimeMetrics.MemStats.PauseNs)
-	if err := r.Register("runtime.MemStats.PauseTotalNs", runtimeMetrics.MemStats.PauseTotalNs); err != nil {
-		log.Printf("[ERROR] registering runtime.MemStats.PauseTotalNs %v", err)
+	if err := r.Register("runtime.MemStats.StackInuse", rs.metrics.MemStats.StackInuse); err != nil {
+		DefaultErrorHandler.HandleError(err)
 	}
-	if err := r.Register("runtime.MemStats.StackInuse", runtimeMetrics.MemStats.StackInuse); err != nil {
-		log.Printf("[ERROR] registering runtime.MemStats.StackInuse %v", err)
+	if err := r.Register("runtime.MemStats.StackSys", rs.metrics.MemStats.StackSys); err != nil {
+		DefaultErrorHandler.HandleError(err)
 	}
-	if err := r.Register("runtime.MemStats.StackSys", runtimeMetrics.MemStats.StackSys); err != nil {
-		log.Printf("[ERROR] registering runtime.MemStats.StackSys %v", err)
+	if err := r.Register("runtime.MemStats.Sys", rs.metrics.MemStats.Sys); err != nil {
+		DefaultErrorHandler.HandleError(err)
 	}
-	if err := r.Register("runtime.MemStats.Sys", runtimeMetrics.MemStats.Sys); err != nil {
-		log.Printf("[ERROR] registering runtime.MemStats.Sys %v", err)
+	if err := r.Register("runtime.MemStats.TotalAlloc", rs.metrics.MemStats.TotalAlloc); err != nil {
+		DefaultErrorHandler.HandleError(err)
 	}
-	if err := r.Register("runtime.MemStats.TotalAlloc", runtimeMetrics.MemStats.TotalAlloc); err != nil {
-		log.Printf("[ERROR] registering runtime.MemStats.TotalAlloc %v", err)
+	if err := r.Register("runtime.NumCgoCall", rs.metrics.NumCgoCall); err != nil {
+		DefaultErrorHandler.HandleError(err)
 	}
-	if err := r.Register("runtime.NumCgoCall", runtimeMetrics.NumCgoCall); err != nil {
-		log.Printf("[ERROR] registering runtime.NumCgoCall %v", err)
+	if err := r.Register("runtime.NumGoroutine", rs.metrics.NumGoroutine); err != nil {
+		DefaultErrorHandler.HandleError(err)
 	}
-	if err := r.Register("runtime.NumGoroutine", runtimeMetrics.NumGoroutine); err != nil {
-		log.Printf("[ERROR] registering runtime.NumGoroutine %v", err)
+	if err := r.Register("runtime.ReadMemStats", rs.metrics.ReadMemStats); err != nil {
+		DefaultErrorHandler.HandleError(err)
 	}
-	// r.Register("runtime.ReadMemStats", runtimeMetrics.ReadMemStats)
+	return rs
 }
@@ -2,15 +2,76 @@ package influx
 
 import "time"
 
+// RuntimeCollectorOptions configures the points written by a RuntimeCollector.
+type RuntimeCollectorOptions struct {
+	// Measurement is the measurement name, "gopprof" by default
+	Measurement string
+	// Tags are added to every point
+	Tags map[string]string
+	// RuntimeMetrics reads runtime/metrics instead of runtime.ReadMemStats,
+	// which stops the world. MemStats fields are read from their runtime/metrics
+	// equivalents, the ones without equivalent (DebugGC, EnableGC, LastGC,
+	// Lookups, PauseTotalNs) and ReadMemStats timer are not reported, PauseNs
+	// are approximated by the buckets of the pause histogram. Scheduler latency
+	// percentiles, GC CPU fraction and heap goal are added. It requires go1.16.
+	RuntimeMetrics bool
+}
+
+// RuntimeCollector periodically writes the Go runtime statistics to a
+// Writer. Every collector has its own registry and state, so several
+// collectors don't affect each other.
+type RuntimeCollector struct {
+	collector
+	writer   *Writer
+	opts     RuntimeCollectorOptions
+	registry Registry
+	stats    *runtimeStats
+}
+
 // NewRuntimeCollector starts goroutine which write to influx writer every duration
-func NewRuntimeCollector(influx *Writer, d time.Duration) {
+func NewRuntimeCollector(influx *Writer, d time.Duration) *RuntimeCollector {
+	c := NewCustomRuntimeCollector(influx, d, RuntimeCollectorOptions{})
+	c.Start()
+	return c
+}
+
+// NewCustomRuntimeCollector creates a collector with the options, it writes
+// nothing until Start is called.
+func NewCustomRuntimeCollector(influx *Writer, d time.Duration, opts RuntimeCollectorOptions) *RuntimeCollector {
+	if opts.Measurement == "" {
+		opts.Measurement = runtimeMeasurement
+	}
 	r := NewRegistry()
-	RegisterRuntimeMemStats(r)
-	go func() {
-		for range time.Tick(d) {
-			CaptureRuntimeMemStatsOnce(r)
-			metric := newRuntimeMetric(r)
-			influx.Write(metric)
-		}
-	}()
+	stats := newRuntimeStats(r)
+	if opts.RuntimeMetrics {
+		stats.reader = newRuntimeMetricsReader(r, ErrorHandlerFunc(influx.handleError))
+	}
+	c := &RuntimeCollector{
+		writer:   influx,
+		opts:     opts,
+		registry: r,
+		stats:    stats,
+	}
+	c.interval, c.collect = d, c.Collect
+	return c
+}
+
+// Close stops the collector and releases its metrics, it must not be
+// started again.
+func (c *RuntimeCollector) Close() {
+	c.Stop()
+	c.stats.release(c.registry)
+}
+
+// Collect captures the statistics and writes them now.
+func (c *RuntimeCollector) Collect() {
+	c.stats.capture()
+	c.writer.Write(c.metric())
+}
+
+// metric snapshots the registry with the collector measurement and tags
+func (c *RuntimeCollector) metric() Metric {
+	m := newRegistryMetric(c.opts.Measurement, c.registry).(runtimeMetric)
+	m.tags = copyTags(c.opts.Tags)
+	return m
 }
@@ -0,0 +1,103 @@
+package influx
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntimeCollector(t *testing.T) {
+	var mutex sync.Mutex
+	var lines []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mutex.Lock()
+		lines = append(lines, strings.Split(strings.TrimSpace(string(body)), "\n")...)
+		mutex.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	w, err := NewWriter(Config{Endpoint: srv.URL, BatchInterval: "1h"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	// collectors don't share the state, each one counts its own reads
+	first := NewRuntimeCollector(w, time.Millisecond)
+	second := NewCustomRuntimeCollector(w, time.Hour, RuntimeCollectorOptions{Measurement: "runtime", Tags: map[string]string{"app": "pool"}})
+	time.Sleep(20 * time.Millisecond)
+	first.Stop()
+	first.Stop()
+	second.Collect()
+	second.Start()
+	second.Stop()
+	assert.EqualValues(t, 1, second.stats.metrics.ReadMemStats.Count())
+	assert.True(t, first.stats.metrics.ReadMemStats.Count() > 1)
+	assert.NoError(t, w.Close())
+
+	NewRuntimeCollector(w, 0).Close() // the default interval is used
+
+	// closed collectors don't leave their meters ticking
+	first.Close()
+	second.Close()
+	arbiter.mutex.Lock()
+	for _, c := range []*RuntimeCollector{first, second} {
+		assert.NotContains(t, arbiter.meters, c.stats.metrics.ReadMemStats.(*StandardTimer).meter)
+	}
+	arbiter.mutex.Unlock()
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	var gopprof, custom int
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "gopprof "):
+			gopprof++
+		case strings.HasPrefix(line, "runtime,app=pool "):
+			custom++
+		}
+	}
+	assert.True(t, gopprof > 1, "%d gopprof points", gopprof)
+	assert.Equal(t, 1, custom)
+}
+
+func TestGCPauses(t *testing.T) {
+	var ms runtime.MemStats
+	for i := range ms.PauseNs {
+		ms.PauseNs[i] = uint64(i)
+	}
+	ms.NumGC = 3
+	assert.Equal(t, []uint64{0, 1, 2}, gcPauses(&ms, 0))
+	assert.Equal(t, []uint64{2}, gcPauses(&ms, 2))
+	assert.Empty(t, gcPauses(&ms, 3))
+
+	// the buffer wrapped around since the previous read
+	ms.NumGC = 258
+	assert.Equal(t, []uint64{254, 255, 0, 1}, gcPauses(&ms, 254))
+	assert.Len(t, gcPauses(&ms, 0), 256)
+}
+
+func TestRuntimeMetrics(t *testing.T) {
+	c := NewCustomRuntimeCollector(nil, time.Hour, RuntimeCollectorOptions{RuntimeMetrics: true})
+	runtime.GC()
+	c.stats.capture()
+	values := newRegistryMetric("runtime", c.registry).Values()
+	assert.True(t, values["runtime.MemStats.PauseNs.count"].(int64) > 0)
+	assert.True(t, values["runtime.MemStats.HeapAlloc"].(int64) > 0)
+	assert.True(t, values["runtime.MemStats.NumGC"].(int64) > 0)
+	// runtime.ReadMemStats isn't called, fields without equivalent are not reported
+	assert.EqualValues(t, 0, c.stats.metrics.ReadMemStats.Count())
+	assert.NotContains(t, values, "runtime.ReadMemStats.count")
+	assert.NotContains(t, values, "runtime.MemStats.LastGC")
+	assert.Contains(t, values, "runtime.NumCgoCall")
+	assert.True(t, values["runtime.HeapGoal"].(int64) > 0)
+	assert.Contains(t, values, "runtime.SchedLatency.p99")
+	assert.Contains(t, values, "runtime.GCCPUFraction")
+}
@@ -2,14 +2,17 @@ package influx
 
 import "time"
 
+const runtimeMeasurement = "gopprof"
+
 type runtimeMetric struct {
-	tags   map[string]string
-	values map[string]interface{}
-	time   time.Time
+	measurement string
+	tags        map[string]string
+	values      map[string]interface{}
+	time        time.Time
 }
 
 func (r runtimeMetric) Measurement() string {
-	return "gopprof"
+	return r.measurement
 }
 
 func (r runtimeMetric) Tags() map[string]string {
@@ -24,19 +27,55 @@ func (r runtimeMetric) Time() time.Time {
 	return r.time
 }
 func newRuntimeMetric(r Registry) Metric {
+	return newRegistryMetric(runtimeMeasurement, r)
+}
+
+// newRegistryMetric snapshots all metrics of the registry as fields of one measurement
+func newRegistryMetric(measurement string, r Registry) Metric {
 	tm := time.Now().UTC()
 	values := make(map[string]interface{})
 	r.Each(func(name string, value interface{}) {
-		switch metric := value.(type) {
-		case Gauge:
-			values[name] = metric.Value()
-		case Counter:
-			values[name] = metric.Count()
-		}
+		metricValues(values, name, value)
 	})
 	return runtimeMetric{
-		time:   tm,
-		values: values,
-		tags:   nil,
+		measurement: measurement,
+		time:        tm,
+		values:      values,
+		tags:        nil,
+	}
+}
+
+// metricValues flattens the metric into fields named after the metric, an
+// empty name gives bare fields like value, count or p99
+func metricValues(values map[string]interface{}, name string, metric interface{}) {
+	switch metric := metric.(type) {
+	case Gauge:
+		values[fieldName(name, "")] = metric.Value()
+	case GaugeFloat64:
+		values[fieldName(name, "")] = metric.Value()
+	case Counter:
+		if name == "" {
+			name = "count"
+		}
+		values[name] = metric.Count()
+	case Histogram:
+		histogramValues(values, name, metric)
+	case Meter:
+		meterValues(values, name, metric)
+	case Timer:
+		timerValues(values, name, metric)
+	}
+}
+
+// fieldName joins the metric name and the name of the statistic
+func fieldName(name, stat string) string {
+	switch {
+	case name == "" && stat == "":
+		return "value"
+	case name == "":
+		return stat
+	case stat == "":
+		return name
 	}
+	return name + "." + stat
 }
@@ -0,0 +1,242 @@
+//go:build go1.16
+// +build go1.16
+
+package influx
+
+import (
+	"math"
+	"runtime"
+	"runtime/metrics"
+)
+
+// Names of runtime/metrics samples, the ones unsupported by the Go version
+// are skipped
+const (
+	metricSchedLatencies = "/sched/latencies:seconds"
+	metricGCCPU          = "/cpu/classes/gc/total:cpu-seconds"
+	metricTotalCPU       = "/cpu/classes/total:cpu-seconds"
+	metricHeapGoal       = "/gc/heap/goal:bytes"
+	// metricGCPauses replaced metricGCPausesOld in go1.22
+	metricGCPauses    = "/sched/pauses/total/gc:seconds"
+	metricGCPausesOld = "/gc/pauses:seconds"
+)
+
+// memStatsSamples are the runtime/metrics samples which give runtime.MemStats
+// fields, the sum of the samples is the value of the field
+var memStatsSamples = []struct {
+	field   func(ms *runtime.MemStats) *uint64
+	samples []string
+}{
+	{func(ms *runtime.MemStats) *uint64 { return &ms.Alloc }, []string{"/memory/classes/heap/objects:bytes"}},
+	{func(ms *runtime.MemStats) *uint64 { return &ms.BuckHashSys }, []string{"/memory/classes/profiling/buckets:bytes"}},
+	{func(ms *runtime.MemStats) *uint64 { return &ms.Frees }, []string{"/gc/heap/frees:objects", "/gc/heap/tiny/allocs:objects"}},
+	{func(ms *runtime.MemStats) *uint64 { return &ms.HeapAlloc }, []string{"/memory/classes/heap/objects:bytes"}},
+	{func(ms *runtime.MemStats) *uint64 { return &ms.HeapIdle }, []string{"/memory/classes/heap/free:bytes", "/memory/classes/heap/released:bytes"}},
+	{func(ms *runtime.MemStats) *uint64 { return &ms.HeapInuse }, []string{"/memory/classes/heap/objects:bytes", "/memory/classes/heap/unused:bytes"}},
+	{func(ms *runtime.MemStats) *uint64 { return &ms.HeapObjects }, []string{"/gc/heap/objects:objects"}},
+	{func(ms *runtime.MemStats) *uint64 { return &ms.HeapReleased }, []string{"/memory/classes/heap/released:bytes"}},
+	{func(ms *runtime.MemStats) *uint64 { return &ms.HeapSys }, []string{"/memory/classes/heap/objects:bytes", "/memory/classes/heap/unused:bytes", "/memory/classes/heap/free:bytes", "/memory/classes/heap/released:bytes"}},
+	{func(ms *runtime.MemStats) *uint64 { return &ms.Mallocs }, []string{"/gc/heap/allocs:objects", "/gc/heap/tiny/allocs:objects"}},
+	{func(ms *runtime.MemStats) *uint64 { return &ms.MCacheInuse }, []string{"/memory/classes/metadata/mcache/inuse:bytes"}},
+	{func(ms *runtime.MemStats) *uint64 { return &ms.MCacheSys }, []string{"/memory/classes/metadata/mcache/inuse:bytes", "/memory/classes/metadata/mcache/free:bytes"}},
+	{func(ms *runtime.MemStats) *uint64 { return &ms.MSpanInuse }, []string{"/memory/classes/metadata/mspan/inuse:bytes"}},
+	{func(ms *runtime.MemStats) *uint64 { return &ms.MSpanSys }, []string{"/memory/classes/metadata/mspan/inuse:bytes", "/memory/classes/metadata/mspan/free:bytes"}},
+	{func(ms *runtime.MemStats) *uint64 { return &ms.NextGC }, []string{metricHeapGoal}},
+	{func(ms *runtime.MemStats) *uint64 { return &ms.StackInuse }, []string{"/memory/classes/heap/stacks:bytes"}},
+	{func(ms *runtime.MemStats) *uint64 { return &ms.StackSys }, []string{"/memory/classes/heap/stacks:bytes", "/memory/classes/os-stacks:bytes"}},
+	{func(ms *runtime.MemStats) *uint64 { return &ms.Sys }, []string{"/memory/classes/total:bytes"}},
+	{func(ms *runtime.MemStats) *uint64 { return &ms.TotalAlloc }, []string{"/gc/heap/allocs:bytes"}},
+}
+
+// metricNumGC is the sample of runtime.MemStats.NumGC
+const metricNumGC = "/gc/cycles/total:gc-cycles"
+
+// unsupportedMemStats are the metrics runtime/metrics don't provide, they
+// are not reported instead of being zero
+var unsupportedMemStats = []string{
+	"runtime.MemStats.DebugGC",
+	"runtime.MemStats.EnableGC",
+	"runtime.MemStats.LastGC",
+	"runtime.MemStats.Lookups",
+	"runtime.MemStats.PauseTotalNs",
+	"runtime.ReadMemStats",
+}
+
+// schedLatencyPercentiles are reported of the scheduler latencies
+var schedLatencyPercentiles = []struct {
+	name string
+	p    float64
+}{
+	{"p50", 0.5},
+	{"p90", 0.9},
+	{"p99", 0.99},
+}
+
+// runtimeMetricsReader reads statistics of runtime/metrics which, unlike
+// runtime.ReadMemStats, doesn't stop the world. It replaces ReadMemStats for
+// the MemStats fields runtime/metrics provide, the rest is not reported.
+// Distributions and CPU time are cumulative, they are reported for the time
+// since the previous read.
+type runtimeMetricsReader struct {
+	samples      []metrics.Sample
+	index        map[string]int // positions of the samples by name
+	schedCounts  []uint64
+	pauseCounts  []uint64
+	gcCPU        float64
+	totalCPU     float64
+	schedLatency []GaugeFloat64
+	gcCPUFrac    GaugeFloat64
+	heapGoal     Gauge
+}
+
+func newRuntimeMetricsReader(r Registry, h ErrorHandler) *runtimeMetricsReader {
+	m := &runtimeMetricsReader{
+		index:     make(map[string]int),
+		gcCPUFrac: NewGaugeFloat64(),
+		heapGoal:  NewGauge(),
+	}
+	add := func(name string) {
+		if _, ok := m.index[name]; !ok {
+			m.index[name] = len(m.samples)
+			m.samples = append(m.samples, metrics.Sample{Name: name})
+		}
+	}
+	for _, name := range []string{metricSchedLatencies, metricGCCPU, metricTotalCPU, metricHeapGoal, metricGCPauses, metricGCPausesOld, metricNumGC} {
+		add(name)
+	}
+	for _, f := range memStatsSamples {
+		for _, name := range f.samples {
+			add(name)
+		}
+	}
+
+	for _, name := range unsupportedMemStats {
+		r.Unregister(name)
+	}
+	for _, p := range schedLatencyPercentiles {
+		g := NewGaugeFloat64()
+		m.schedLatency = append(m.schedLatency, g)
+		if err := r.Register("runtime.SchedLatency."+p.name, g); err != nil {
+			h.HandleError(err)
+		}
+	}
+	if err := r.Register("runtime.GCCPUFraction", m.gcCPUFrac); err != nil {
+		h.HandleError(err)
+	}
+	if err := r.Register("runtime.HeapGoal", m.heapGoal); err != nil {
+		h.HandleError(err)
+	}
+	return m
+}
+
+// read updates the metrics from runtime/metrics and fills the MemStats
+// fields they provide, it returns the GC pauses since the previous read
+func (m *runtimeMetricsReader) read(ms *runtime.MemStats) []uint64 {
+	metrics.Read(m.samples)
+	for _, f := range memStatsSamples {
+		var v uint64
+		for _, name := range f.samples {
+			v += m.uint64(name)
+		}
+		*f.field(ms) = v
+	}
+	ms.NumGC = uint32(m.uint64(metricNumGC))
+	m.heapGoal.Update(int64(m.uint64(metricHeapGoal)))
+
+	if v := m.value(metricSchedLatencies); v.Kind() == metrics.KindFloat64Histogram {
+		m.readSchedLatencies(v.Float64Histogram())
+	}
+
+	gc, total := m.value(metricGCCPU), m.value(metricTotalCPU)
+	if gc.Kind() == metrics.KindFloat64 && total.Kind() == metrics.KindFloat64 {
+		if d := total.Float64() - m.totalCPU; d > 0 {
+			m.gcCPUFrac.Update((gc.Float64() - m.gcCPU) / d)
+		}
+		m.gcCPU, m.totalCPU = gc.Float64(), total.Float64()
+	}
+
+	pauses := m.value(metricGCPauses)
+	if pauses.Kind() != metrics.KindFloat64Histogram {
+		pauses = m.value(metricGCPausesOld)
+	}
+	if pauses.Kind() == metrics.KindFloat64Histogram {
+		return histogramPauses(pauses.Float64Histogram(), &m.pauseCounts)
+	}
+	return nil
+}
+
+// value returns the value of the sample, KindBad if it isn't supported
+func (m *runtimeMetricsReader) value(name string) metrics.Value {
+	return m.samples[m.index[name]].Value
+}
+
+// uint64 returns the value of the sample, 0 if it isn't supported
+func (m *runtimeMetricsReader) uint64(name string) uint64 {
+	if v := m.value(name); v.Kind() == metrics.KindUint64 {
+		return v.Uint64()
+	}
+	return 0
+}
+
+// readSchedLatencies updates the percentiles of latencies since the previous read
+func (m *runtimeMetricsReader) readSchedLatencies(h *metrics.Float64Histogram) {
+	counts, total := histogramDelta(h, &m.schedCounts)
+	if total == 0 {
+		return
+	}
+	for i, p := range schedLatencyPercentiles {
+		m.schedLatency[i].Update(bucketPercentile(h.Buckets, counts, total, p.p))
+	}
+}
+
+// histogramPauses returns the pauses in nanoseconds since the previous read,
+// every pause is the upper boundary of its bucket
+func histogramPauses(h *metrics.Float64Histogram, prev *[]uint64) []uint64 {
+	counts, total := histogramDelta(h, prev)
+	pauses := make([]uint64, 0, total)
+	for i, c := range counts {
+		pause := uint64(bucketBoundary(h.Buckets, i) * 1e9)
+		for ; c > 0; c-- {
+			pauses = append(pauses, pause)
+		}
+	}
+	return pauses
+}
+
+// histogramDelta returns the counts of the buckets since the previous read
+// and their total, prev is updated to the current counts
+func histogramDelta(h *metrics.Float64Histogram, prev *[]uint64) ([]uint64, uint64) {
+	if len(*prev) != len(h.Counts) {
+		*prev = make([]uint64, len(h.Counts))
+	}
+	counts := make([]uint64, len(h.Counts))
+	var total uint64
+	for i, c := range h.Counts {
+		counts[i] = c - (*prev)[i]
+		total += counts[i]
+	}
+	copy(*prev, h.Counts)
+	return counts, total
+}
+
+// bucketPercentile returns the boundary of the bucket holding the percentile
+func bucketPercentile(buckets []float64, counts []uint64, total uint64, p float64) float64 {
+	rank := uint64(math.Ceil(p * float64(total)))
+	var seen uint64
+	for i, c := range counts {
+		seen += c
+		if seen >= rank {
+			return bucketBoundary(buckets, i)
+		}
+	}
+	return buckets[len(buckets)-1]
+}
+
+// bucketBoundary returns the upper boundary of the bucket, the lower one for
+// the last unbounded bucket
+func bucketBoundary(buckets []float64, i int) float64 {
+	if math.IsInf(buckets[i+1], 1) {
+		return buckets[i]
+	}
+	return buckets[i+1]
+}
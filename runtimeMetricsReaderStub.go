@@ -0,0 +1,19 @@
+//go:build !go1.16
+// +build !go1.16
+
+package influx
+
+import (
+	"errors"
+	"runtime"
+)
+
+// runtimeMetricsReader is unavailable, runtime/metrics appeared in go1.16
+type runtimeMetricsReader struct{}
+
+func newRuntimeMetricsReader(r Registry, h ErrorHandler) *runtimeMetricsReader {
+	h.HandleError(errors.New("influx: runtime/metrics requires go1.16"))
+	return nil
+}
+
+func (m *runtimeMetricsReader) read(ms *runtime.MemStats) []uint64 { return nil }
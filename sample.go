@@ -0,0 +1,366 @@
+package influx
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+const rescaleThreshold = time.Hour
+
+// Sample maintains a statistically-significant selection of values from a stream.
+type Sample interface {
+	Clear()
+	Count() int64
+	Max() int64
+	Mean() float64
+	Min() int64
+	Percentile(float64) float64
+	Percentiles([]float64) []float64
+	Size() int
+	StdDev() float64
+	Sum() int64
+	Update(int64)
+	Values() []int64
+	Variance() float64
+}
+
+// UniformSample is a uniform sample using Vitter's Algorithm R, every value
+// of the stream has the same chance to be kept in the reservoir.
+//
+// <http://www.cs.umd.edu/~samir/498/vitter.pdf>
+type UniformSample struct {
+	count         int64
+	mutex         sync.Mutex
+	reservoirSize int
+	values        []int64
+}
+
+// NewUniformSample constructs a new uniform sample with the given reservoir size.
+func NewUniformSample(reservoirSize int) Sample {
+	return &UniformSample{
+		reservoirSize: reservoirSize,
+		values:        make([]int64, 0, reservoirSize),
+	}
+}
+
+// Clear clears all samples.
+func (s *UniformSample) Clear() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.count = 0
+	s.values = make([]int64, 0, s.reservoirSize)
+}
+
+// Count returns the number of samples recorded, which may exceed the
+// reservoir size.
+func (s *UniformSample) Count() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.count
+}
+
+// Max returns the maximum value in the sample.
+func (s *UniformSample) Max() int64 {
+	return sampleMax(s.Values())
+}
+
+// Mean returns the mean of the values in the sample.
+func (s *UniformSample) Mean() float64 {
+	return sampleMean(s.Values())
+}
+
+// Min returns the minimum value in the sample.
+func (s *UniformSample) Min() int64 {
+	return sampleMin(s.Values())
+}
+
+// Percentile returns an arbitrary percentile of values in the sample.
+func (s *UniformSample) Percentile(p float64) float64 {
+	return samplePercentiles(s.Values(), []float64{p})[0]
+}
+
+// Percentiles returns a slice of arbitrary percentiles of values in the sample.
+func (s *UniformSample) Percentiles(ps []float64) []float64 {
+	return samplePercentiles(s.Values(), ps)
+}
+
+// Size returns the size of the sample, which is at most the reservoir size.
+func (s *UniformSample) Size() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.values)
+}
+
+// StdDev returns the standard deviation of the values in the sample.
+func (s *UniformSample) StdDev() float64 {
+	return math.Sqrt(sampleVariance(s.Values()))
+}
+
+// Sum returns the sum of the values in the sample.
+func (s *UniformSample) Sum() int64 {
+	return sampleSum(s.Values())
+}
+
+// Update samples a new value.
+func (s *UniformSample) Update(v int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.count++
+	if len(s.values) < s.reservoirSize {
+		s.values = append(s.values, v)
+		return
+	}
+	if r := rand.Int63n(s.count); r < int64(len(s.values)) {
+		s.values[int(r)] = v
+	}
+}
+
+// Values returns a copy of the values in the sample.
+func (s *UniformSample) Values() []int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	values := make([]int64, len(s.values))
+	copy(values, s.values)
+	return values
+}
+
+// Variance returns the variance of the values in the sample.
+func (s *UniformSample) Variance() float64 {
+	return sampleVariance(s.Values())
+}
+
+// ExpDecaySample is an exponentially-decaying sample using a forward-decaying
+// priority reservoir, recent values are kept with a higher probability, so
+// the sample represents roughly the last 5 minutes with alpha 0.015.
+//
+// <http://dimacs.rutgers.edu/~graham/pubs/papers/fwddecay.pdf>
+type ExpDecaySample struct {
+	alpha         float64
+	count         int64
+	mutex         sync.Mutex
+	reservoirSize int
+	t0, t1        time.Time
+	values        expDecaySampleHeap
+}
+
+// NewExpDecaySample constructs a new exponentially-decaying sample with the
+// given reservoir size and alpha.
+func NewExpDecaySample(reservoirSize int, alpha float64) Sample {
+	s := &ExpDecaySample{
+		alpha:         alpha,
+		reservoirSize: reservoirSize,
+		t0:            time.Now(),
+		values:        make(expDecaySampleHeap, 0, reservoirSize),
+	}
+	s.t1 = s.t0.Add(rescaleThreshold)
+	return s
+}
+
+// Clear clears all samples.
+func (s *ExpDecaySample) Clear() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.count = 0
+	s.t0 = time.Now()
+	s.t1 = s.t0.Add(rescaleThreshold)
+	s.values = s.values[:0]
+}
+
+// Count returns the number of samples recorded, which may exceed the
+// reservoir size.
+func (s *ExpDecaySample) Count() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.count
+}
+
+// Max returns the maximum value in the sample.
+func (s *ExpDecaySample) Max() int64 {
+	return sampleMax(s.Values())
+}
+
+// Mean returns the mean of the values in the sample.
+func (s *ExpDecaySample) Mean() float64 {
+	return sampleMean(s.Values())
+}
+
+// Min returns the minimum value in the sample.
+func (s *ExpDecaySample) Min() int64 {
+	return sampleMin(s.Values())
+}
+
+// Percentile returns an arbitrary percentile of values in the sample.
+func (s *ExpDecaySample) Percentile(p float64) float64 {
+	return samplePercentiles(s.Values(), []float64{p})[0]
+}
+
+// Percentiles returns a slice of arbitrary percentiles of values in the sample.
+func (s *ExpDecaySample) Percentiles(ps []float64) []float64 {
+	return samplePercentiles(s.Values(), ps)
+}
+
+// Size returns the size of the sample, which is at most the reservoir size.
+func (s *ExpDecaySample) Size() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.values)
+}
+
+// StdDev returns the standard deviation of the values in the sample.
+func (s *ExpDecaySample) StdDev() float64 {
+	return math.Sqrt(sampleVariance(s.Values()))
+}
+
+// Sum returns the sum of the values in the sample.
+func (s *ExpDecaySample) Sum() int64 {
+	return sampleSum(s.Values())
+}
+
+// Update samples a new value.
+func (s *ExpDecaySample) Update(v int64) {
+	s.update(time.Now(), v)
+}
+
+// Values returns a copy of the values in the sample.
+func (s *ExpDecaySample) Values() []int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	values := make([]int64, len(s.values))
+	for i, v := range s.values {
+		values[i] = v.v
+	}
+	return values
+}
+
+// Variance returns the variance of the values in the sample.
+func (s *ExpDecaySample) Variance() float64 {
+	return sampleVariance(s.Values())
+}
+
+// update samples a new value at a particular timestamp, priorities are
+// rescaled every hour to keep them in the float64 range.
+func (s *ExpDecaySample) update(t time.Time, v int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.count++
+	if len(s.values) == s.reservoirSize {
+		heap.Pop(&s.values)
+	}
+	heap.Push(&s.values, expDecaySample{
+		k: math.Exp(t.Sub(s.t0).Seconds()*s.alpha) / rand.Float64(),
+		v: v,
+	})
+	if t.After(s.t1) {
+		t0 := s.t0
+		s.t0 = t
+		s.t1 = s.t0.Add(rescaleThreshold)
+		scale := math.Exp(-s.alpha * s.t0.Sub(t0).Seconds())
+		for i := range s.values {
+			s.values[i].k *= scale
+		}
+	}
+}
+
+// expDecaySample represents an individual sample in a heap.
+type expDecaySample struct {
+	k float64
+	v int64
+}
+
+// expDecaySampleHeap is a min-heap of samples by priority, the sample with
+// the lowest priority is evicted first.
+type expDecaySampleHeap []expDecaySample
+
+func (h expDecaySampleHeap) Len() int            { return len(h) }
+func (h expDecaySampleHeap) Less(i, j int) bool  { return h[i].k < h[j].k }
+func (h expDecaySampleHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *expDecaySampleHeap) Push(x interface{}) { *h = append(*h, x.(expDecaySample)) }
+func (h *expDecaySampleHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
+
+func sampleMax(values []int64) int64 {
+	if len(values) == 0 {
+		return 0
+	}
+	max := int64(math.MinInt64)
+	for _, v := range values {
+		if max < v {
+			max = v
+		}
+	}
+	return max
+}
+
+func sampleMin(values []int64) int64 {
+	if len(values) == 0 {
+		return 0
+	}
+	min := int64(math.MaxInt64)
+	for _, v := range values {
+		if min > v {
+			min = v
+		}
+	}
+	return min
+}
+
+func sampleSum(values []int64) int64 {
+	var sum int64
+	for _, v := range values {
+		sum += v
+	}
+	return sum
+}
+
+func sampleMean(values []int64) float64 {
+	if len(values) == 0 {
+		return 0.0
+	}
+	return float64(sampleSum(values)) / float64(len(values))
+}
+
+func sampleVariance(values []int64) float64 {
+	if len(values) == 0 {
+		return 0.0
+	}
+	m := sampleMean(values)
+	var sum float64
+	for _, v := range values {
+		d := float64(v) - m
+		sum += d * d
+	}
+	return sum / float64(len(values))
+}
+
+// samplePercentiles interpolates between the closest ranks, the values are sorted in place
+func samplePercentiles(values []int64, ps []float64) []float64 {
+	scores := make([]float64, len(ps))
+	size := len(values)
+	if size == 0 {
+		return scores
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	for i, p := range ps {
+		pos := p * float64(size+1)
+		switch {
+		case pos < 1.0:
+			scores[i] = float64(values[0])
+		case pos >= float64(size):
+			scores[i] = float64(values[size-1])
+		default:
+			lower := float64(values[int(pos)-1])
+			upper := float64(values[int(pos)])
+			scores[i] = lower + (pos-math.Floor(pos))*(upper-lower)
+		}
+	}
+	return scores
+}
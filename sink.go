@@ -0,0 +1,80 @@
+package influx
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb/client/v2"
+)
+
+// Sink receives the batches built by the Writer workers. WriteBatch is
+// called by several workers concurrently, ctx is canceled when the shutdown
+// deadline passes. Errors are retried unless isRetryable considers them
+// permanent, *HTTPError and net.Error give the most precise decisions.
+type Sink interface {
+	WriteBatch(ctx context.Context, bp client.BatchPoints) error
+	Close() error
+}
+
+// pinger is implemented by sinks which can tell whether they are reachable,
+// the spool is replayed to them only after a successful ping
+type pinger interface {
+	Ping(timeout time.Duration) (time.Duration, string, error)
+}
+
+// clientSink writes to an influx client
+type clientSink struct {
+	client client.Client
+}
+
+// NewClientSink adapts a client, like the ones of influxdb client/v2
+// package, to a Sink. The client doesn't support contexts, so a write in
+// progress isn't interrupted by the shutdown deadline, the http client
+// created from Config limits it by WriteTimeout instead.
+func NewClientSink(c client.Client) Sink {
+	return &clientSink{client: c}
+}
+
+func (s *clientSink) WriteBatch(ctx context.Context, bp client.BatchPoints) error {
+	return s.client.Write(bp)
+}
+
+func (s *clientSink) Ping(timeout time.Duration) (time.Duration, string, error) {
+	return s.client.Ping(timeout)
+}
+
+func (s *clientSink) Close() error {
+	return s.client.Close()
+}
+
+// lineSink writes line protocol to an io.Writer
+type lineSink struct {
+	mutex sync.Mutex
+	w     io.Writer
+}
+
+// NewLineSink creates a Sink writing batches as line protocol to w, e.g.
+// os.Stdout for debugging. Every batch is written with a single Write call,
+// Close doesn't close w.
+func NewLineSink(w io.Writer) Sink {
+	return &lineSink{w: w}
+}
+
+func (s *lineSink) WriteBatch(ctx context.Context, bp client.BatchPoints) error {
+	var b bytes.Buffer
+	for _, p := range bp.Points() {
+		b.WriteString(p.PrecisionString(bp.Precision()))
+		b.WriteByte('\n')
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, err := s.w.Write(b.Bytes())
+	return err
+}
+
+func (s *lineSink) Close() error {
+	return nil
+}
@@ -0,0 +1,69 @@
+package influx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/influxdata/influxdb/client/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+// testSink fails the first writes and keeps the batches
+type testSink struct {
+	mutex   sync.Mutex
+	fail    int
+	batches []client.BatchPoints
+	closed  bool
+}
+
+func (s *testSink) WriteBatch(ctx context.Context, bp client.BatchPoints) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.fail > 0 {
+		s.fail--
+		return errors.New("unavailable")
+	}
+	s.batches = append(s.batches, bp)
+	return nil
+}
+
+func (s *testSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.closed = true
+	return nil
+}
+
+func TestSink(t *testing.T) {
+	sink := &testSink{fail: 1}
+	w, err := NewWriter(Config{Sink: sink, Database: "pool", BatchInterval: "1h", RetryMaxAttempts: 2, RetryBaseBackoff: "1ms", ErrorHandler: NopErrorHandler{}})
+	if !assert.NoError(t, err) {
+		return
+	}
+	w.Write(SimpleMetric{Name: "sink", ValuesMap: map[string]interface{}{"value": 1}})
+	assert.NoError(t, w.Flush(context.Background()))
+	assert.NoError(t, w.Close())
+
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	assert.True(t, sink.closed)
+	if assert.Len(t, sink.batches, 1) {
+		assert.Equal(t, "pool", sink.batches[0].Database())
+		assert.Len(t, sink.batches[0].Points(), 1)
+	}
+}
+
+func TestLineSink(t *testing.T) {
+	var b bytes.Buffer
+	w, err := NewWriter(Config{Sink: NewLineSink(&b), Precision: "s", BatchInterval: "1h"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	w.Write(SimpleMetric{Name: "shares", TagsMap: map[string]string{"coin": "btc"}, ValuesMap: map[string]interface{}{"value": 1}, CreateTime: time.Unix(1500000000, 0)})
+	assert.NoError(t, w.Close())
+	assert.Equal(t, "shares,coin=btc value=1i 1500000000\n", b.String())
+}
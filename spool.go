@@ -0,0 +1,252 @@
+package influx
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/influxdb/client/v2"
+	"github.com/influxdata/influxdb/models"
+)
+
+const (
+	spoolExt         = ".lp"
+	spoolPingTimeout = 5 * time.Second
+
+	headerDML             = "# DML\n"
+	headerDatabase        = "# CONTEXT-DATABASE: "
+	headerRetentionPolicy = "# CONTEXT-RETENTION-POLICY: "
+	headerPrecision       = "# PRECISION: "
+)
+
+var errSpoolLimit = errors.New("spool size or age limit exceeded")
+
+// spool keeps batches which couldn't be delivered as line protocol segment
+// files, one file per batch. File names start with the creation time, so
+// segments are replayed in order and their age survives process restarts.
+// Segments can be loaded with `influx -import`, which ignores their precision
+// header, so the precision of the writer has to be passed with -precision.
+type spool struct {
+	dir      string
+	maxBytes int64
+	maxAge   time.Duration
+	mutex    sync.Mutex
+	seq      uint64
+	handler  ErrorHandler
+}
+
+func newSpool(dir string, maxBytes int64, maxAge time.Duration, handler ErrorHandler) (*spool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &spool{dir: dir, maxBytes: maxBytes, maxAge: maxAge, handler: handler}, nil
+}
+
+// append stores the batch as a new segment, errSpoolLimit is returned when
+// the segment alone exceeds the limits and is discarded right away
+func (s *spool) append(batch client.BatchPoints) error {
+	var b bytes.Buffer
+	b.WriteString(headerDML)
+	encodeBatch(&b, batch)
+
+	s.mutex.Lock()
+	s.seq++
+	name := fmt.Sprintf("%020d-%06d%s", time.Now().UnixNano(), s.seq%1000000, spoolExt)
+	s.mutex.Unlock()
+
+	// write to a temporary file first, so the replayer never sees a partial segment
+	tmp := filepath.Join(s.dir, "."+name)
+	if err := ioutil.WriteFile(tmp, b.Bytes(), 0644); err != nil {
+		os.Remove(tmp)
+		s.handler.HandleError(&SpoolError{Op: "append", Segment: name, Err: err})
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(s.dir, name)); err != nil {
+		s.handler.HandleError(&SpoolError{Op: "append", Segment: name, Err: err})
+		return err
+	}
+	for _, removed := range s.trim() {
+		if removed == name {
+			return errSpoolLimit
+		}
+	}
+	return nil
+}
+
+// segments returns names of the stored segments from the oldest to the newest
+func (s *spool) segments() ([]os.FileInfo, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	segments := files[:0]
+	for _, f := range files {
+		if f.Mode().IsRegular() && !strings.HasPrefix(f.Name(), ".") && strings.HasSuffix(f.Name(), spoolExt) {
+			segments = append(segments, f)
+		}
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].Name() < segments[j].Name() })
+	return segments, nil
+}
+
+// trim removes the oldest segments exceeding maxBytes or maxAge, it returns
+// names of the removed segments
+func (s *spool) trim() []string {
+	segments, err := s.segments()
+	if err != nil {
+		s.handler.HandleError(&SpoolError{Op: "list", Segment: s.dir, Err: err})
+		return nil
+	}
+	var removed []string
+	var size int64
+	for _, f := range segments {
+		size += f.Size()
+	}
+	for _, f := range segments {
+		expired := s.maxAge > 0 && time.Since(segmentTime(f.Name())) > s.maxAge
+		if !expired && (s.maxBytes <= 0 || size <= s.maxBytes) {
+			break
+		}
+		s.handler.HandleError(&SpoolError{Op: "discard", Segment: f.Name(), Err: errSpoolLimit})
+		s.remove(f.Name())
+		removed = append(removed, f.Name())
+		size -= f.Size()
+	}
+	return removed
+}
+
+// read loads the segment back into a batch
+func (s *spool) read(name string) (client.BatchPoints, error) {
+	data, err := ioutil.ReadFile(filepath.Join(s.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	batches, err := decodeBatches(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(batches) != 1 {
+		return nil, fmt.Errorf("segment has %d batches", len(batches))
+	}
+	return batches[0], nil
+}
+
+func (s *spool) remove(name string) {
+	if err := os.Remove(filepath.Join(s.dir, name)); err != nil && !os.IsNotExist(err) {
+		s.handler.HandleError(&SpoolError{Op: "remove", Segment: name, Err: err})
+	}
+}
+
+// replay sends the stored segments in order while the sink accepts them
+func (s *spool) replay(sink Sink, write func(client.BatchPoints) error) {
+	s.trim()
+	segments, err := s.segments()
+	if err != nil || len(segments) == 0 {
+		return
+	}
+	if p, ok := sink.(pinger); ok {
+		if _, _, err := p.Ping(spoolPingTimeout); err != nil {
+			return
+		}
+	}
+	for _, f := range segments {
+		batch, err := s.read(f.Name())
+		if err != nil {
+			s.handler.HandleError(&SpoolError{Op: "read", Segment: f.Name(), Err: err})
+			s.remove(f.Name())
+			continue
+		}
+		if err := write(batch); err != nil {
+			if isRetryable(err) {
+				s.handler.HandleError(&SpoolError{Op: "replay", Segment: f.Name(), Err: err})
+				return
+			}
+			s.handler.HandleError(&SpoolError{Op: "discard", Segment: f.Name(), Err: err})
+		}
+		s.remove(f.Name())
+	}
+}
+
+// encodeBatch writes the batch as line protocol preceded by the context
+// headers of `influx -import`, precision is an extension of the format
+func encodeBatch(b *bytes.Buffer, batch client.BatchPoints) {
+	b.WriteString(headerDatabase + batch.Database() + "\n")
+	if rp := batch.RetentionPolicy(); rp != "" {
+		b.WriteString(headerRetentionPolicy + rp + "\n")
+	}
+	b.WriteString(headerPrecision + batch.Precision() + "\n")
+	for _, p := range batch.Points() {
+		b.WriteString(p.PrecisionString(batch.Precision()))
+		b.WriteByte('\n')
+	}
+}
+
+// decodeBatches parses data written by encodeBatch, there is a batch for
+// every block of points following the headers. A database header resets the
+// retention policy, encodeBatch omits the default one.
+func decodeBatches(data []byte) ([]client.BatchPoints, error) {
+	var batches []client.BatchPoints
+	var cfg client.BatchPointsConfig
+	var lines bytes.Buffer
+	flush := func() error {
+		if lines.Len() == 0 {
+			return nil
+		}
+		batch, err := client.NewBatchPoints(cfg)
+		if err != nil {
+			return err
+		}
+		points, err := models.ParsePointsWithPrecision(lines.Bytes(), time.Now().UTC(), cfg.Precision)
+		if err != nil {
+			return err
+		}
+		for _, p := range points {
+			batch.AddPoint(client.NewPointFrom(p))
+		}
+		batches = append(batches, batch)
+		lines.Reset()
+		return nil
+	}
+
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if !bytes.HasPrefix(line, []byte("#")) {
+			if len(bytes.TrimSpace(line)) > 0 {
+				lines.Write(line)
+				lines.WriteByte('\n')
+			}
+			continue
+		}
+		if err := flush(); err != nil {
+			return nil, err
+		}
+		switch header := string(line); {
+		case strings.HasPrefix(header, headerDatabase):
+			cfg.Database = strings.TrimPrefix(header, headerDatabase)
+			cfg.RetentionPolicy = ""
+		case strings.HasPrefix(header, headerRetentionPolicy):
+			cfg.RetentionPolicy = strings.TrimPrefix(header, headerRetentionPolicy)
+		case strings.HasPrefix(header, headerPrecision):
+			cfg.Precision = strings.TrimPrefix(header, headerPrecision)
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return batches, nil
+}
+
+func segmentTime(name string) time.Time {
+	ns, err := strconv.ParseInt(strings.SplitN(name, "-", 2)[0], 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
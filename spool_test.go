@@ -0,0 +1,122 @@
+package influx
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpoolReplay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "influx-spool")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	var mutex sync.Mutex
+	var written []string
+	down := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path == "/write" {
+			assert.Equal(t, "spooldb", r.URL.Query().Get("db"))
+			body, _ := ioutil.ReadAll(r.Body)
+			written = append(written, string(body))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	cfg := Config{
+		Endpoint:      srv.URL,
+		Database:      "spooldb",
+		BatchInterval: "10ms",
+		Precision:     "s",
+		SpoolDir:      dir,
+	}
+	w, err := NewWriter(cfg)
+	if !assert.NoError(t, err) {
+		return
+	}
+	w.Write(SimpleMetric{Name: "spooled", ValuesMap: map[string]interface{}{"value": 1}, CreateTime: time.Unix(1500000000, 0)})
+	assert.NoError(t, w.Close())
+
+	s, _ := newSpool(dir, 0, 0, NopErrorHandler{})
+	segments, err := s.segments()
+	assert.NoError(t, err)
+	assert.Len(t, segments, 1)
+
+	// a restarted writer delivers the spooled batch once influx is up
+	mutex.Lock()
+	down = false
+	mutex.Unlock()
+	w, err = NewWriter(cfg)
+	if !assert.NoError(t, err) {
+		return
+	}
+	for i := 0; i < 100; i++ {
+		if segments, _ = s.segments(); len(segments) == 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.NoError(t, w.Close())
+	assert.Len(t, segments, 0)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if assert.Len(t, written, 1) {
+		assert.Equal(t, "spooled value=1i 1500000000", strings.TrimSpace(written[0]))
+	}
+}
+
+func TestSpoolTrim(t *testing.T) {
+	dir, err := ioutil.TempDir("", "influx-spool")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := newSpool(dir, 150, 0, NopErrorHandler{})
+	if !assert.NoError(t, err) {
+		return
+	}
+	for i := 0; i < 5; i++ {
+		batch := newBatch("db", "s")
+		point, err := newPoint(nil, SimpleMetric{Name: "trim", ValuesMap: map[string]interface{}{"value": i}, CreateTime: time.Unix(1500000000, 0)})
+		assert.NoError(t, err)
+		batch.AddPoint(point)
+		assert.NoError(t, s.append(batch))
+	}
+	segments, err := s.segments()
+	assert.NoError(t, err)
+	if assert.Len(t, segments, 2) {
+		batch, err := s.read(segments[1].Name())
+		assert.NoError(t, err)
+		assert.Equal(t, "db", batch.Database())
+		assert.Equal(t, "s", batch.Precision())
+		assert.Equal(t, "trim value=4i 1500000000", batch.Points()[0].PrecisionString("s"))
+	}
+
+	// a segment larger than the limit isn't kept
+	s.maxBytes = 10
+	batch := newBatch("db", "s")
+	point, _ := newPoint(nil, SimpleMetric{Name: "trim", ValuesMap: map[string]interface{}{"value": 5}, CreateTime: time.Unix(1500000000, 0)})
+	batch.AddPoint(point)
+	assert.Equal(t, errSpoolLimit, s.append(batch))
+	segments, err = s.segments()
+	assert.NoError(t, err)
+	assert.Empty(t, segments)
+}
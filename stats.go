@@ -0,0 +1,123 @@
+package influx
+
+import (
+	"time"
+
+	"github.com/influxdata/influxdb/client/v2"
+)
+
+const writerMeasurement = "go_influx_writer"
+
+// WriterStats is a snapshot of the Writer pipeline counters, counters are
+// totals since the writer was created.
+type WriterStats struct {
+	// Queued is the number of messages accepted by Write
+	Queued int64
+	// DroppedFull is the number of messages discarded because the queue was full
+	DroppedFull int64
+	// DroppedInvalid is the number of metrics which couldn't be converted to points
+	DroppedInvalid int64
+	// PointsWritten is the number of points accepted by influx
+	PointsWritten int64
+	// PointsSpooled is the number of points saved to the spool
+	PointsSpooled int64
+	// BatchesFailed is the number of batches which couldn't be written after all retries
+	BatchesFailed int64
+	// WriteLatency is the duration of the last batch write
+	WriteLatency time.Duration
+	// QueueDepth is the number of messages waiting in the queue
+	QueueDepth int64
+	// CardinalityViolations is the number of exceeded cardinality limits
+	CardinalityViolations int64
+	// DroppedCardinality is the number of metrics discarded because of cardinality limits
+	DroppedCardinality int64
+	// ActiveEndpoint is the endpoint written last, it changes with failover
+	ActiveEndpoint string
+}
+
+// writerStats keeps the counters in a registry, so they can be reported as a metric
+type writerStats struct {
+	registry              Registry
+	queued                Counter
+	droppedFull           Counter
+	droppedInvalid        Counter
+	pointsWritten         Counter
+	pointsSpooled         Counter
+	batchesFailed         Counter
+	writeLatency          Gauge
+	queueDepth            Gauge
+	cardinalityViolations Counter
+	droppedCardinality    Counter
+	activeEndpoint        Gauge
+}
+
+func newWriterStats() *writerStats {
+	r := NewRegistry()
+	return &writerStats{
+		registry:              r,
+		queued:                r.GetOrRegister("queued", NewCounter).(Counter),
+		droppedFull:           r.GetOrRegister("dropped_full", NewCounter).(Counter),
+		droppedInvalid:        r.GetOrRegister("dropped_invalid", NewCounter).(Counter),
+		pointsWritten:         r.GetOrRegister("points_written", NewCounter).(Counter),
+		pointsSpooled:         r.GetOrRegister("points_spooled", NewCounter).(Counter),
+		batchesFailed:         r.GetOrRegister("batches_failed", NewCounter).(Counter),
+		writeLatency:          r.GetOrRegister("write_latency_ns", NewGauge).(Gauge),
+		queueDepth:            r.GetOrRegister("queue_depth", NewGauge).(Gauge),
+		cardinalityViolations: r.GetOrRegister("cardinality_violations", NewCounter).(Counter),
+		droppedCardinality:    r.GetOrRegister("dropped_cardinality", NewCounter).(Counter),
+		activeEndpoint:        r.GetOrRegister("active_endpoint", NewGauge).(Gauge),
+	}
+}
+
+// Stats returns the current values of the writer counters
+func (s *Writer) Stats() WriterStats {
+	s.stats.queueDepth.Update(int64(len(s.messageCh)))
+	endpoint := s.endpoint
+	if s.failover != nil {
+		_, endpoint = s.failover.Active()
+	}
+	return WriterStats{
+		Queued:                s.stats.queued.Count(),
+		DroppedFull:           s.stats.droppedFull.Count(),
+		DroppedInvalid:        s.stats.droppedInvalid.Count(),
+		PointsWritten:         s.stats.pointsWritten.Count(),
+		PointsSpooled:         s.stats.pointsSpooled.Count(),
+		BatchesFailed:         s.stats.batchesFailed.Count(),
+		WriteLatency:          time.Duration(s.stats.writeLatency.Value()),
+		QueueDepth:            s.stats.queueDepth.Value(),
+		CardinalityViolations: s.stats.cardinalityViolations.Count(),
+		DroppedCardinality:    s.stats.droppedCardinality.Count(),
+		ActiveEndpoint:        endpoint,
+	}
+}
+
+// selfReporter writes the writer stats as go_influx_writer measurement until the writer is closed
+func (s *Writer) selfReporter(d time.Duration) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.stats.queueDepth.Update(int64(len(s.messageCh)))
+			s.Write(newRegistryMetric(writerMeasurement, s.stats.registry))
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// write sends the batch to the sink and accounts for the result
+func (s *Writer) write(batch client.BatchPoints) error {
+	start := time.Now()
+	err := s.sink.WriteBatch(s.ctx, batch)
+	s.stats.writeLatency.Update(int64(time.Since(start)))
+	if err == nil {
+		s.stats.pointsWritten.Inc(int64(len(batch.Points())))
+	}
+	if s.failover != nil {
+		i, _ := s.failover.Active()
+		s.stats.activeEndpoint.Update(int64(i))
+	}
+	return err
+}
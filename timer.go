@@ -0,0 +1,128 @@
+package influx
+
+import "time"
+
+// Timer captures the duration and rate of events.
+type Timer interface {
+	Count() int64
+	Max() int64
+	Mean() float64
+	Min() int64
+	Percentile(float64) float64
+	Percentiles([]float64) []float64
+	Rate1() float64
+	Rate5() float64
+	Rate15() float64
+	RateMean() float64
+	StdDev() float64
+	Stop()
+	Sum() int64
+	Time(func())
+	Update(time.Duration)
+	UpdateSince(time.Time)
+	Variance() float64
+}
+
+// StandardTimer is the standard implementation of a Timer and uses a
+// Histogram for durations in nanoseconds and a Meter for the rate.
+type StandardTimer struct {
+	histogram Histogram
+	meter     Meter
+}
+
+// NewTimer constructs a new StandardTimer using an exponentially-decaying
+// sample with the same reservoir size and alpha as UNIX load averages.
+func NewTimer() Timer {
+	return NewCustomTimer(NewHistogram(NewExpDecaySample(1028, 0.015)), NewMeter())
+}
+
+// NewCustomTimer constructs a new StandardTimer from a Histogram and a Meter.
+func NewCustomTimer(h Histogram, m Meter) Timer {
+	return &StandardTimer{histogram: h, meter: m}
+}
+
+// Count returns the number of events recorded.
+func (t *StandardTimer) Count() int64 { return t.histogram.Count() }
+
+// Max returns the maximum value in the sample.
+func (t *StandardTimer) Max() int64 { return t.histogram.Max() }
+
+// Mean returns the mean of the values in the sample.
+func (t *StandardTimer) Mean() float64 { return t.histogram.Mean() }
+
+// Min returns the minimum value in the sample.
+func (t *StandardTimer) Min() int64 { return t.histogram.Min() }
+
+// Percentile returns an arbitrary percentile of the values in the sample.
+func (t *StandardTimer) Percentile(p float64) float64 {
+	return t.histogram.Percentile(p)
+}
+
+// Percentiles returns a slice of arbitrary percentiles of the values in the
+// sample.
+func (t *StandardTimer) Percentiles(ps []float64) []float64 {
+	return t.histogram.Percentiles(ps)
+}
+
+// Rate1 returns the one-minute moving average rate of events per second.
+func (t *StandardTimer) Rate1() float64 { return t.meter.Rate1() }
+
+// Rate5 returns the five-minute moving average rate of events per second.
+func (t *StandardTimer) Rate5() float64 { return t.meter.Rate5() }
+
+// Rate15 returns the fifteen-minute moving average rate of events per second.
+func (t *StandardTimer) Rate15() float64 { return t.meter.Rate15() }
+
+// RateMean returns the meter's mean rate of events per second.
+func (t *StandardTimer) RateMean() float64 { return t.meter.RateMean() }
+
+// StdDev returns the standard deviation of the values in the sample.
+func (t *StandardTimer) StdDev() float64 { return t.histogram.StdDev() }
+
+// Stop stops the meter of the timer.
+func (t *StandardTimer) Stop() { t.meter.Stop() }
+
+// Sum returns the sum in the sample.
+func (t *StandardTimer) Sum() int64 { return t.histogram.Sum() }
+
+// Time records the duration of the execution of the given function.
+func (t *StandardTimer) Time(f func()) {
+	ts := time.Now()
+	f()
+	t.Update(time.Since(ts))
+}
+
+// Update records the duration of an event.
+func (t *StandardTimer) Update(d time.Duration) {
+	t.histogram.Update(int64(d))
+	t.meter.Mark(1)
+}
+
+// UpdateSince records the duration of an event that started at a time and ends now.
+func (t *StandardTimer) UpdateSince(ts time.Time) {
+	t.Update(time.Since(ts))
+}
+
+// Variance returns the variance of the values in the sample.
+func (t *StandardTimer) Variance() float64 { return t.histogram.Variance() }
+
+// timerValues flattens the timer statistics into fields like histogramValues,
+// durations are in nanoseconds
+func timerValues(values map[string]interface{}, name string, t Timer) {
+	if st, ok := t.(*StandardTimer); ok {
+		histogramValues(values, name, st.histogram)
+	} else {
+		values[fieldName(name, "count")] = t.Count()
+		values[fieldName(name, "min")] = t.Min()
+		values[fieldName(name, "max")] = t.Max()
+		values[fieldName(name, "mean")] = t.Mean()
+		values[fieldName(name, "stddev")] = t.StdDev()
+		for _, p := range reportedPercentiles {
+			values[fieldName(name, p.name)] = t.Percentile(p.p)
+		}
+	}
+	values[fieldName(name, "m1")] = t.Rate1()
+	values[fieldName(name, "m5")] = t.Rate5()
+	values[fieldName(name, "m15")] = t.Rate15()
+	values[fieldName(name, "mean_rate")] = t.RateMean()
+}
@@ -0,0 +1,40 @@
+package influx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimer(t *testing.T) {
+	tm := NewTimer()
+	tm.Update(10 * time.Millisecond)
+	tm.UpdateSince(time.Now().Add(-20 * time.Millisecond))
+	tm.Time(func() { time.Sleep(time.Millisecond) })
+	assert.EqualValues(t, 3, tm.Count())
+	assert.True(t, tm.Min() >= int64(time.Millisecond))
+	assert.True(t, tm.Max() >= int64(20*time.Millisecond))
+	assert.True(t, tm.RateMean() > 0)
+
+	r := NewRegistry()
+	assert.NoError(t, r.Register("fetch", tm))
+	values := newRegistryMetric("test", r).Values()
+	assert.Equal(t, int64(3), values["fetch.count"])
+	assert.Contains(t, values, "fetch.p99")
+	assert.Contains(t, values, "fetch.mean_rate")
+}
+
+func TestRuntimeReadMemStatsTimer(t *testing.T) {
+	r := NewRegistry()
+	RegisterRuntimeMemStats(r)
+	CaptureRuntimeMemStatsOnce(r)
+	values := newRuntimeMetric(r).Values()
+	assert.Equal(t, int64(1), values["runtime.ReadMemStats.count"])
+
+	UnregisterRuntimeMemStats(r)
+	assert.Empty(t, newRuntimeMetric(r).Values())
+	registryStatsMutex.Lock()
+	assert.NotContains(t, registryStats, r)
+	registryStatsMutex.Unlock()
+}
@@ -0,0 +1,212 @@
+package influx
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// vector is implemented by the metric vectors, the reporter writes every
+// child as a separate series tagged with its label values
+type vector interface {
+	each(func(tags map[string]string, metric interface{}))
+}
+
+// metricVec keeps child metrics by their tag values
+type metricVec struct {
+	keys         []string
+	newMetric    func() interface{}
+	mutex        sync.RWMutex
+	children     map[string]vecChild
+	errorHandler ErrorHandler
+}
+
+type vecChild struct {
+	tags   map[string]string
+	metric interface{}
+}
+
+func newMetricVec(keys []string, newMetric func() interface{}) *metricVec {
+	return &metricVec{
+		keys:         keys,
+		newMetric:    newMetric,
+		children:     make(map[string]vecChild),
+		errorHandler: DefaultErrorHandler,
+	}
+}
+
+// SetErrorHandler sets the handler of errors of WithLabelValues,
+// DefaultErrorHandler by default. Use Config.ErrorHandler of the writer the
+// vector is reported to, so the errors end up in one place.
+func (v *metricVec) SetErrorHandler(h ErrorHandler) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.errorHandler = h
+}
+
+// get returns the child for the values creating it if needed
+func (v *metricVec) get(values []string) (interface{}, error) {
+	if len(values) != len(v.keys) {
+		return nil, fmt.Errorf("influx: %d label values for %d tag keys %v", len(values), len(v.keys), v.keys)
+	}
+	id := strings.Join(values, "\xff")
+	v.mutex.RLock()
+	child, ok := v.children[id]
+	v.mutex.RUnlock()
+	if ok {
+		return child.metric, nil
+	}
+
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if child, ok := v.children[id]; ok {
+		return child.metric, nil
+	}
+	tags := make(map[string]string, len(v.keys))
+	for i, k := range v.keys {
+		tags[k] = values[i]
+	}
+	child = vecChild{tags: tags, metric: v.newMetric()}
+	v.children[id] = child
+	return child.metric, nil
+}
+
+// with is get which reports the error and returns a metric which isn't
+// reported, so callers don't have to check for nil
+func (v *metricVec) with(values []string) interface{} {
+	m, err := v.get(values)
+	if err != nil {
+		v.mutex.RLock()
+		h := v.errorHandler
+		v.mutex.RUnlock()
+		h.HandleError(err)
+		return v.newMetric()
+	}
+	return m
+}
+
+// Delete removes the child with the given label values, it reports whether
+// the child existed.
+func (v *metricVec) Delete(values ...string) bool {
+	id := strings.Join(values, "\xff")
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	child, ok := v.children[id]
+	if ok {
+		if m, ok := child.metric.(stoppable); ok {
+			m.Stop()
+		}
+		delete(v.children, id)
+	}
+	return ok
+}
+
+// Reset removes all children.
+func (v *metricVec) Reset() {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	for id, child := range v.children {
+		if m, ok := child.metric.(stoppable); ok {
+			m.Stop()
+		}
+		delete(v.children, id)
+	}
+}
+
+// each calls f for every child ordered by label values, tags must not be modified
+func (v *metricVec) each(f func(tags map[string]string, metric interface{})) {
+	v.mutex.RLock()
+	ids := make([]string, 0, len(v.children))
+	for id := range v.children {
+		ids = append(ids, id)
+	}
+	children := make([]vecChild, 0, len(ids))
+	sort.Strings(ids)
+	for _, id := range ids {
+		children = append(children, v.children[id])
+	}
+	v.mutex.RUnlock()
+	for _, child := range children {
+		f(child.tags, child.metric)
+	}
+}
+
+// CounterVec is a set of counters with the same tag keys and different values.
+type CounterVec struct {
+	*metricVec
+}
+
+// NewCounterVec constructs a new CounterVec with the given tag keys.
+func NewCounterVec(keys ...string) *CounterVec {
+	return &CounterVec{newMetricVec(keys, func() interface{} { return NewCounter() })}
+}
+
+// GetMetricWithLabelValues returns the counter for the tag values in order of
+// the keys, it fails if the number of values doesn't match.
+func (v *CounterVec) GetMetricWithLabelValues(values ...string) (Counter, error) {
+	m, err := v.get(values)
+	if err != nil {
+		return nil, err
+	}
+	return m.(Counter), nil
+}
+
+// WithLabelValues is GetMetricWithLabelValues which reports the error to
+// the error handler of the vector and returns a counter that isn't reported.
+func (v *CounterVec) WithLabelValues(values ...string) Counter {
+	return v.with(values).(Counter)
+}
+
+// GaugeVec is a set of gauges with the same tag keys and different values.
+type GaugeVec struct {
+	*metricVec
+}
+
+// NewGaugeVec constructs a new GaugeVec with the given tag keys.
+func NewGaugeVec(keys ...string) *GaugeVec {
+	return &GaugeVec{newMetricVec(keys, func() interface{} { return NewGauge() })}
+}
+
+// GetMetricWithLabelValues returns the gauge for the tag values in order of
+// the keys, it fails if the number of values doesn't match.
+func (v *GaugeVec) GetMetricWithLabelValues(values ...string) (Gauge, error) {
+	m, err := v.get(values)
+	if err != nil {
+		return nil, err
+	}
+	return m.(Gauge), nil
+}
+
+// WithLabelValues is GetMetricWithLabelValues which reports the error to
+// the error handler of the vector and returns a gauge that isn't reported.
+func (v *GaugeVec) WithLabelValues(values ...string) Gauge {
+	return v.with(values).(Gauge)
+}
+
+// HistogramVec is a set of histograms with the same tag keys and different values.
+type HistogramVec struct {
+	*metricVec
+}
+
+// NewHistogramVec constructs a new HistogramVec with the given tag keys,
+// every child gets its own sample from newSample.
+func NewHistogramVec(newSample func() Sample, keys ...string) *HistogramVec {
+	return &HistogramVec{newMetricVec(keys, func() interface{} { return NewHistogram(newSample()) })}
+}
+
+// GetMetricWithLabelValues returns the histogram for the tag values in order
+// of the keys, it fails if the number of values doesn't match.
+func (v *HistogramVec) GetMetricWithLabelValues(values ...string) (Histogram, error) {
+	m, err := v.get(values)
+	if err != nil {
+		return nil, err
+	}
+	return m.(Histogram), nil
+}
+
+// WithLabelValues is GetMetricWithLabelValues which reports the error to
+// the error handler of the vector and returns a histogram that isn't reported.
+func (v *HistogramVec) WithLabelValues(values ...string) Histogram {
+	return v.with(values).(Histogram)
+}
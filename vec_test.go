@@ -0,0 +1,61 @@
+package influx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVec(t *testing.T) {
+	shares := NewCounterVec("coin", "worker")
+	shares.WithLabelValues("btc", "rig1").Inc(2)
+	shares.WithLabelValues("btc", "rig1").Inc(1)
+	shares.WithLabelValues("ltc", "rig2").Inc(5)
+
+	_, err := shares.GetMetricWithLabelValues("btc")
+	assert.Error(t, err)
+	var handled error
+	shares.SetErrorHandler(ErrorHandlerFunc(func(err error) { handled = err }))
+	shares.WithLabelValues("btc").Inc(1) // not reported
+	assert.Error(t, handled)
+
+	difficulty := NewGaugeVec("coin")
+	difficulty.WithLabelValues("btc").Update(1024)
+	latency := NewHistogramVec(func() Sample { return NewUniformSample(10) }, "coin")
+	latency.WithLabelValues("btc").Update(5)
+	latency.WithLabelValues("ltc").Update(7)
+	assert.True(t, latency.Delete("ltc"))
+	assert.False(t, latency.Delete("ltc"))
+
+	r := NewRegistry()
+	assert.NoError(t, r.Register("pool.shares", shares))
+	assert.NoError(t, r.Register("pool.difficulty", difficulty))
+	assert.NoError(t, r.Register("pool.latency", latency))
+
+	rp := &Reporter{registry: r, opts: ReporterOptions{Mode: ReportPerMetric, Separator: ".", Tags: map[string]string{"coin": "any", "pool": "eu"}}}
+	var series []Metric
+	for _, m := range rp.snapshot(time.Now()) {
+		if m.Measurement() == "pool.shares" {
+			series = append(series, m)
+		}
+	}
+	if assert.Len(t, series, 2) {
+		assert.Equal(t, map[string]string{"coin": "btc", "worker": "rig1", "pool": "eu"}, series[0].Tags())
+		assert.Equal(t, map[string]interface{}{"count": int64(3)}, series[0].Values())
+		assert.Equal(t, map[string]string{"coin": "ltc", "worker": "rig2", "pool": "eu"}, series[1].Tags())
+	}
+
+	// a single measurement still needs a series per tag set
+	rp.opts.Mode = ReportSingle
+	rp.opts.Measurement = "pool"
+	metrics := rp.snapshot(time.Now())
+	assert.Len(t, metrics, 3)
+	for _, m := range metrics {
+		if m.Tags()["worker"] == "" {
+			assert.Equal(t, map[string]string{"coin": "btc", "pool": "eu"}, m.Tags())
+			assert.Equal(t, int64(1024), m.Values()["pool.difficulty"])
+			assert.Equal(t, int64(5), m.Values()["pool.latency.max"])
+		}
+	}
+}